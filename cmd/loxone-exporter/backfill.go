@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const monthFormat = "2006-01"
+
+// labelValueEscaper escapes label values for the OpenMetrics text format
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// backfill prints the statistics the Miniservers keep of their controls as
+// the loxone_values series of their states, in the OpenMetrics format of
+// promtool tsdb create-blocks-from openmetrics
+func backfill(ctx context.Context, cfg *config.Config) int {
+	err := writeBackfill(ctx, cfg, os.Stdout)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	return 0
+}
+
+func writeBackfill(ctx context.Context, cfg *config.Config, out io.Writer) error {
+	location, err := time.LoadLocation(cfg.Backfill.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid backfill.timezone: %v", err)
+	}
+	months, err := backfillMonths(cfg.Backfill, time.Now().In(location), location)
+	if err != nil {
+		return err
+	}
+	miniservers, err := cfg.MiniserverConfigs()
+	if err != nil {
+		return err
+	}
+	collector.RegisterMetrics(cfg)
+	mapper, err := collector.NewStateMapper(cfg)
+	if err != nil {
+		return err
+	}
+	err = loxone.ConfigureDialer(cfg)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(out)
+	metric := cfg.Metrics.Prefix + "_values"
+	fmt.Fprintf(w, "# TYPE %s gauge\n", metric)
+	written := make(map[string]bool)
+	for _, miniserver := range miniservers {
+		logger := log.WithField("miniserver", miniserver.Name)
+		_, address, secure, err := collector.ResolveAddress(ctx, cfg, miniserver, logger)
+		if err != nil {
+			return err
+		}
+		structure, err := collector.FetchStructure(ctx, cfg, miniserver)
+		if err != nil {
+			return fmt.Errorf("unable to download the structure file of %s: %v", miniserver.Name, err)
+		}
+
+		for _, control := range mapper.MapControls(structure, miniserver.Name) {
+			statistic, ok := structure.Statistics[control.UUID]
+			if !ok || !backfillSelected(cfg.Backfill.Controls, control) {
+				continue
+			}
+			points := make([]loxone.StatisticPoint, 0)
+			for _, month := range months {
+				monthPoints, err := loxone.FetchStatistics(ctx, address, secure, miniserver.User, miniserver.Password, control.UUID, month, location)
+				if err != nil {
+					return fmt.Errorf("unable to read the statistics of %s: %v", control.Name, err)
+				}
+				points = append(points, monthPoints...)
+			}
+			logger.Infof("Read %d statistics entries of %s", len(points), control.Name)
+
+			for i, output := range statistic.Outputs {
+				state, ok := outputState(control, output.Name)
+				if !ok {
+					logger.Warnf("No exported state of %s matches the statistics output %q", control.Name, output.Name)
+					continue
+				}
+				labels := formatLabels(collector.SeriesLabels(state.Labels))
+				if written[labels] {
+					logger.Warnf("Statistics output %q of %s has the labels of another one, skipping it", output.Name, control.Name)
+					continue
+				}
+				written[labels] = true
+				for _, point := range points {
+					if i >= len(point.Values) {
+						continue
+					}
+					fmt.Fprintf(w, "%s%s %s %d\n", metric, labels, strconv.FormatFloat(point.Values[i], 'g', -1, 64), point.Time.Unix())
+				}
+			}
+		}
+	}
+	fmt.Fprintln(w, "# EOF")
+	return w.Flush()
+}
+
+// backfillMonths returns the first day of every month to backfill
+func backfillMonths(cfg config.BackfillConfig, now time.Time, location *time.Location) ([]time.Time, error) {
+	parse := func(name string, value string) (time.Time, error) {
+		if value == "" {
+			return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, location), nil
+		}
+		month, err := time.ParseInLocation(monthFormat, value, location)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid backfill.%s %q, use %s", name, value, monthFormat)
+		}
+		return month, nil
+	}
+	from, err := parse("from", cfg.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parse("to", cfg.To)
+	if err != nil {
+		return nil, err
+	}
+	if from.After(to) {
+		return nil, fmt.Errorf("backfill.from %s is after backfill.to %s", from.Format(monthFormat), to.Format(monthFormat))
+	}
+
+	months := make([]time.Time, 0)
+	for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+		months = append(months, month)
+	}
+	return months, nil
+}
+
+// backfillSelected tells whether the control is one of backfill.controls,
+// by name or UUID, all controls are when none is given
+func backfillSelected(controls []string, control collector.ControlStatus) bool {
+	if len(controls) == 0 {
+		return true
+	}
+	for _, selected := range controls {
+		if selected == control.Name || strings.EqualFold(selected, control.UUID) {
+			return true
+		}
+	}
+	return false
+}
+
+// outputState returns the exported state of the control named like a
+// statistics output, e.g. the actual state for the Actual output
+func outputState(control collector.ControlStatus, output string) (collector.StateStatus, bool) {
+	for _, state := range control.States {
+		if state.Exported && strings.EqualFold(state.Name, output) {
+			return state, true
+		}
+	}
+	return collector.StateStatus{}, false
+}
+
+// formatLabels formats labels as {name="value",...}, sorted by name
+func formatLabels(labels prometheus.Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+	"github.com/XciD/loxone-prometheus-exporter/sink"
+
+	"github.com/prometheus/exporter-toolkit/web"
+	log "github.com/sirupsen/logrus"
+)
+
+// runCommand runs the subcommand of the arguments, it returns the exit code
+func runCommand(ctx context.Context, cfg *config.Config) int {
+	// The output of the subcommands goes to stdout, keep the logs apart
+	log.SetOutput(os.Stderr)
+
+	switch cfg.Args[0] {
+	case "list-controls":
+		return listControls(ctx, cfg)
+	case "gen-dashboard":
+		return genDashboard(ctx, cfg)
+	case "gen-rules":
+		return genRules(ctx, cfg)
+	case "backfill":
+		return backfill(ctx, cfg)
+	case "check-config":
+		if len(cfg.Args) != 2 {
+			log.Error("Usage: check-config <file>")
+			return 1
+		}
+		return checkConfig(cfg.Args[1])
+	case "install-service":
+		return installService(cfg)
+	case "uninstall-service":
+		return uninstallService(cfg)
+	default:
+		log.Errorf("Unknown command %q", cfg.Args[0])
+		return 1
+	}
+}
+
+// mapControls downloads the structure files, or reads the one of --replay,
+// and returns their controls with the labels the exporter gives their states
+func mapControls(ctx context.Context, cfg *config.Config) ([]collector.ControlStatus, error) {
+	controls := make([]collector.ControlStatus, 0)
+	err := eachStructure(ctx, cfg, func(mapper *collector.StateMapper, structure *loxone.Structure, miniserver string) {
+		controls = append(controls, mapper.MapControls(structure, miniserver)...)
+	})
+	return controls, err
+}
+
+// eachStructure downloads the structure files, or reads the one of --replay,
+// and hands them to mapped with the mapper of the config
+func eachStructure(ctx context.Context, cfg *config.Config, mapped func(*collector.StateMapper, *loxone.Structure, string)) error {
+	var miniservers []config.MiniserverConfig
+	var err error
+	if cfg.Replay == "" {
+		miniservers, err = cfg.MiniserverConfigs()
+		if err != nil {
+			return err
+		}
+	}
+	collector.RegisterMetrics(cfg)
+	mapper, err := collector.NewStateMapper(cfg)
+	if err != nil {
+		return err
+	}
+	err = loxone.ConfigureDialer(cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Replay != "" {
+		structure, miniserver, err := collector.RecordedStructure(cfg.Replay)
+		if err != nil {
+			return fmt.Errorf("unable to read the structure file of %s: %v", cfg.Replay, err)
+		}
+		mapped(mapper, structure, miniserver)
+	}
+	for _, miniserver := range miniservers {
+		structure, err := collector.FetchStructure(ctx, cfg, miniserver)
+		if err != nil {
+			return fmt.Errorf("unable to download the structure file of %s: %v", miniserver.Name, err)
+		}
+		mapped(mapper, structure, miniserver.Name)
+	}
+	return nil
+}
+
+// listControls prints the controls of the structure files with the labels the
+// exporter gives their states
+func listControls(ctx context.Context, cfg *config.Config) int {
+	controls, err := mapControls(ctx, cfg)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	switch cfg.Output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(controls)
+	case "table":
+		err = printControls(controls)
+	default:
+		err = fmt.Errorf("unknown output format %q, use table or json", cfg.Output)
+	}
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	return 0
+}
+
+// checkConfig reports every error of a config file without connecting to
+// anything, syntax errors carry their line number
+func checkConfig(file string) int {
+	cfg, err := config.Load(file)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	errs := make([]error, 0)
+	if err := config.CheckKeys(file); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.Replay == "" {
+		if _, err := cfg.MiniserverConfigs(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := configureLogging(cfg); err != nil {
+		errs = append(errs, err)
+	}
+	collector.RegisterMetrics(cfg)
+	errs = append(errs, collector.ValidateConfig(cfg)...)
+	errs = append(errs, sink.ValidateWebhooks(cfg.Webhooks)...)
+	errs = append(errs, sink.ValidateConnectionHooks(cfg.ConnectionHooks)...)
+	if err := loxone.ConfigureDialer(cfg); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.Web.Config.File != "" {
+		if err := web.Validate(cfg.Web.Config.File); err != nil {
+			errs = append(errs, fmt.Errorf("web config %s: %v", cfg.Web.Config.File, err))
+		}
+	}
+
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	fmt.Printf("%s is valid\n", file)
+	return 0
+}
+
+// printControls prints a line per state, with its labels or why it isn't exported
+func printControls(controls []collector.ControlStatus) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MINISERVER\tCONTROL\tROOM\tTYPE\tUUID\tSTATE\tSTATE UUID\tLABELS")
+	for _, control := range controls {
+		for _, state := range control.States {
+			labels := "not exported"
+			if control.Filtered != "" {
+				labels = "filtered by " + control.Filtered
+			} else if state.Exported {
+				pairs := make([]string, 0, len(state.Labels))
+				for name, value := range state.Labels {
+					pairs = append(pairs, fmt.Sprintf("%s=%q", name, value))
+				}
+				sort.Strings(pairs)
+				labels = "{" + strings.Join(pairs, ",") + "}"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				control.Miniserver, control.Name, control.Room, control.Type, control.UUID, state.Name, state.UUID, labels)
+		}
+	}
+	return w.Flush()
+}
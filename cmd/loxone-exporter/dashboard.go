@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	panelWidth  = 12
+	panelHeight = 8
+)
+
+// panelKind is a panel of the generated dashboard, the states it shows by control type
+type panelKind struct {
+	title string
+	// unit is the Grafana unit of the panel
+	unit string
+	// timeline shows on/off states as a state timeline instead of a graph
+	timeline bool
+	states   map[string][]string
+}
+
+// panelKinds are the panels of every room, in order
+var panelKinds = []panelKind{
+	{title: "Temperatures", unit: "celsius", states: map[string][]string{
+		"IRoomControllerV2": {"tempActual", "tempTarget"},
+		"IRoomController":   {"tempActual", "tempTarget"},
+	}},
+	{title: "Power", unit: "kwatt", states: map[string][]string{
+		"Meter": {"actual"},
+	}},
+	{title: "Switches", timeline: true, states: map[string][]string{
+		"Switch":          {"active"},
+		"Pushbutton":      {"active"},
+		"InfoOnlyDigital": {"active"},
+	}},
+	{title: "Lights", unit: "percent", states: map[string][]string{
+		"Dimmer":    {"position"},
+		"EIBDimmer": {"position"},
+	}},
+	{title: "Shading", unit: "percentunit", states: map[string][]string{
+		"Jalousie": {"position"},
+	}},
+	{title: "Sensors", states: map[string][]string{
+		"InfoOnlyAnalog": {"value"},
+	}},
+}
+
+// roomKey is a room of a Miniserver, a row of the dashboard
+type roomKey struct {
+	miniserver, room string
+}
+
+// genDashboard prints a Grafana dashboard with a row per room and a panel
+// per kind of control found in the structure files
+func genDashboard(ctx context.Context, cfg *config.Config) int {
+	controls, err := mapControls(ctx, cfg)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(dashboard(controls, cfg.Metrics.Prefix))
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	return 0
+}
+
+// dashboard builds the Grafana dashboard model of the controls
+func dashboard(controls []collector.ControlStatus, prefix string) map[string]interface{} {
+	// The types and states of every panel kind found per room
+	found := make(map[roomKey][]map[string]map[string]bool)
+	for _, control := range controls {
+		for _, state := range control.States {
+			if !state.Exported {
+				continue
+			}
+			for i, kind := range panelKinds {
+				if !contains(kind.states[control.Type], state.Name) {
+					continue
+				}
+				key := roomKey{state.Labels["miniserver"], state.Labels["room"]}
+				if found[key] == nil {
+					found[key] = make([]map[string]map[string]bool, len(panelKinds))
+				}
+				if found[key][i] == nil {
+					found[key][i] = map[string]map[string]bool{"type": {}, "state": {}}
+				}
+				found[key][i]["type"][control.Type] = true
+				found[key][i]["state"][state.Name] = true
+			}
+		}
+	}
+
+	rooms := make([]roomKey, 0, len(found))
+	for key := range found {
+		rooms = append(rooms, key)
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].miniserver != rooms[j].miniserver {
+			return rooms[i].miniserver < rooms[j].miniserver
+		}
+		return rooms[i].room < rooms[j].room
+	})
+
+	// With several Miniservers the rows tell which one the room belongs to
+	several := len(rooms) > 0 && rooms[0].miniserver != rooms[len(rooms)-1].miniserver
+
+	panels := make([]interface{}, 0)
+	id, y := 1, 0
+	for _, room := range rooms {
+		title := room.room
+		if several {
+			title = room.miniserver + " / " + room.room
+		}
+		panels = append(panels, map[string]interface{}{
+			"id": id, "type": "row", "title": title, "collapsed": false,
+			"gridPos": gridPos(0, y, 24, 1),
+		})
+		id++
+		y++
+
+		x := 0
+		for i, kind := range panelKinds {
+			selection := found[room][i]
+			if selection == nil {
+				continue
+			}
+			query := fmt.Sprintf(`%s_values{miniserver=%s,room=%s,type=~%s,state=~%s}`, prefix,
+				strconv.Quote(room.miniserver), strconv.Quote(room.room),
+				strconv.Quote(alternatives(selection["type"])), strconv.Quote(alternatives(selection["state"])))
+			panels = append(panels, panel(id, kind, query, gridPos(x, y, panelWidth, panelHeight)))
+			id++
+			if x += panelWidth; x >= 24 {
+				x, y = 0, y+panelHeight
+			}
+		}
+		if x > 0 {
+			y += panelHeight
+		}
+	}
+
+	return map[string]interface{}{
+		"title":         "Loxone",
+		"uid":           "loxone-exporter",
+		"tags":          []string{"loxone"},
+		"schemaVersion": 39,
+		"editable":      true,
+		"time":          map[string]string{"from": "now-24h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []interface{}{map[string]interface{}{
+				"name": "datasource", "label": "Data source", "type": "datasource", "query": "prometheus",
+			}},
+		},
+		"panels": panels,
+	}
+}
+
+func panel(id int, kind panelKind, query string, pos map[string]int) map[string]interface{} {
+	panelType := "timeseries"
+	if kind.timeline {
+		panelType = "state-timeline"
+	}
+	return map[string]interface{}{
+		"id":         id,
+		"type":       panelType,
+		"title":      kind.title,
+		"datasource": map[string]string{"type": "prometheus", "uid": "${datasource}"},
+		"gridPos":    pos,
+		"fieldConfig": map[string]interface{}{
+			"defaults":  map[string]interface{}{"unit": kind.unit},
+			"overrides": []interface{}{},
+		},
+		"targets": []interface{}{map[string]interface{}{
+			"refId":        "A",
+			"expr":         query,
+			"legendFormat": "{{control}} {{state}}",
+		}},
+	}
+}
+
+func gridPos(x, y, w, h int) map[string]int {
+	return map[string]int{"x": x, "y": y, "w": w, "h": h}
+}
+
+// alternatives is a regex matching exactly the names
+func alternatives(names map[string]bool) string {
+	quoted := make([]string, 0, len(names))
+	for name := range names {
+		quoted = append(quoted, regexp.QuoteMeta(name))
+	}
+	sort.Strings(quoted)
+	return strings.Join(quoted, "|")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dryRun downloads and maps the structure files like the exporter does after
+// connecting, prints what came out of it and exits without reading events
+func dryRun(ctx context.Context, cfg *config.Config) int {
+	// The summary goes to stdout, keep the logs apart
+	log.SetOutput(os.Stderr)
+
+	summaries := make([]collector.MapSummary, 0)
+	err := eachStructure(ctx, cfg, func(mapper *collector.StateMapper, structure *loxone.Structure, miniserver string) {
+		summaries = append(summaries, mapper.Summarize(structure, miniserver))
+	})
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	errs := collector.ValidateConfig(cfg)
+
+	switch cfg.Output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(summaries)
+	case "table":
+		printSummaries(summaries)
+	default:
+		err = fmt.Errorf("unknown output format %q, use table or json", cfg.Output)
+	}
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	for _, err := range errs {
+		log.Error(err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printSummaries prints a paragraph per Miniserver
+func printSummaries(summaries []collector.MapSummary) {
+	for _, summary := range summaries {
+		filtered := 0
+		for _, count := range summary.Filtered {
+			filtered += count
+		}
+		fmt.Printf("%s: %d controls, %d mapped, %d filtered, %d skipped, %d series\n",
+			summary.Miniserver, summary.Controls, summary.Kept, filtered, len(summary.SkippedControls), summary.Series)
+		for _, filter := range sortedKeys(summary.Filtered) {
+			fmt.Printf("  filtered by %s: %d\n", filter, summary.Filtered[filter])
+		}
+		if len(summary.SkippedControls) > 0 {
+			fmt.Printf("  skipped, without states: %s\n", strings.Join(summary.SkippedControls, ", "))
+		}
+		for _, state := range sortedKeys(summary.UnsupportedStates) {
+			fmt.Printf("  unsupported state %s: %d\n", state, summary.UnsupportedStates[state])
+		}
+		if len(summary.DuplicateUUIDs) > 0 {
+			fmt.Printf("  duplicate state UUIDs: %s\n", strings.Join(summary.DuplicateUUIDs, ", "))
+		}
+	}
+}
+
+func sortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
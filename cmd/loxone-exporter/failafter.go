@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+// failAfter sends an error once a Miniserver stayed disconnected for longer
+// than window, from the start or since it lost its connection. It returns
+// when the context is done.
+func failAfter(ctx context.Context, window time.Duration, miniservers []config.MiniserverConfig, failed chan<- error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	start := time.Now()
+	disconnected := make(map[string]time.Time, len(miniservers))
+	for _, miniserver := range miniservers {
+		disconnected[miniserver.Name] = start
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, miniserver := range miniservers {
+				if collector.IsConnected(miniserver.Name) {
+					disconnected[miniserver.Name] = time.Time{}
+					continue
+				}
+				since := disconnected[miniserver.Name]
+				if since.IsZero() {
+					disconnected[miniserver.Name] = now
+					continue
+				}
+				if now.Sub(since) > window {
+					failed <- fmt.Errorf("no connection to Miniserver %s for %s, giving up", miniserver.Name, window)
+					return
+				}
+			}
+		}
+	}
+}
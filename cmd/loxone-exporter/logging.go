@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// configureLogging sets the log level and format of the config
+func configureLogging(cfg *config.Config) error {
+	level, err := log.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		return err
+	}
+	log.SetLevel(level)
+
+	switch cfg.Log.Format {
+	case "text":
+		log.SetFormatter(&log.TextFormatter{
+			FullTimestamp: true,
+		})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("unknown log format %q, use text or json", cfg.Log.Format)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+	"github.com/XciD/loxone-prometheus-exporter/server"
+	"github.com/XciD/loxone-prometheus-exporter/sink"
+
+	"github.com/prometheus/client_golang/prometheus"
+	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
+	"github.com/prometheus/common/version"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	shutdownTimeout = 5 * time.Second
+	program         = "loxone-exporter"
+)
+
+func main() {
+	log.SetOutput(os.Stdout)
+	log.SetLevel(log.InfoLevel)
+
+	log.SetFormatter(&log.TextFormatter{
+		FullTimestamp: true,
+	})
+
+	os.Exit(runService(run))
+}
+
+// run starts the exporter and blocks until the context is done or it's
+// stopped, it returns the exit code
+func run(ctx context.Context, stop context.CancelFunc) int {
+	// Read config
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	if cfg.Version {
+		fmt.Println(version.Print(program))
+		return 0
+	}
+	err = configureLogging(cfg)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	// Every connection to a Miniserver, /probe targets and dry-run included, goes through the dialer
+	err = loxone.ConfigureDialer(cfg)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	if cfg.DryRun {
+		return dryRun(ctx, cfg)
+	}
+	if len(cfg.Args) > 0 {
+		return runCommand(ctx, cfg)
+	}
+	log.Infof("Starting %s %s", program, version.Info())
+
+	var miniservers []config.MiniserverConfig
+	if cfg.Replay == "" {
+		miniservers, err = cfg.MiniserverConfigs()
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+	}
+
+	if cfg.Web.DisableExporterMetrics {
+		// The default registry comes with the Go and process collectors, a new one is empty
+		registry := prometheus.NewRegistry()
+		prometheus.DefaultRegisterer = registry
+		prometheus.DefaultGatherer = registry
+	}
+
+	// Registering extends the label names, which the state mapper checks relabel rules against
+	collector.RegisterMetrics(cfg)
+	prometheus.MustRegister(versioncollector.NewCollector("loxone_exporter"))
+
+	// Invalid settings, e.g. intervals of 0, would panic later on
+	if errs := collector.ValidateConfig(cfg); len(errs) > 0 {
+		for _, err := range errs {
+			log.Error(err)
+		}
+		return 1
+	}
+
+	mapper, err := collector.NewStateMapper(cfg)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	if cfg.MQTT.Broker != "" || cfg.Influx.URL != "" || cfg.Loki.URL != "" || cfg.Graphite.Address != "" || cfg.RemoteWrite.URL != "" || cfg.OTLP.Endpoint != "" || len(cfg.Webhooks) > 0 || len(cfg.ConnectionHooks) > 0 {
+		sink.RegisterMetrics()
+	}
+	if cfg.MQTT.Broker != "" {
+		publisher := sink.NewMQTT(cfg.MQTT)
+		defer publisher.Close()
+		mapper.AddSink(publisher)
+	}
+	if cfg.Influx.URL != "" {
+		writer := sink.NewInflux(cfg.Influx)
+		defer writer.Close()
+		mapper.AddSink(writer)
+	}
+	if cfg.Loki.URL != "" {
+		loki := sink.NewLoki(cfg.Loki)
+		defer loki.Close()
+		mapper.AddTextSink(loki)
+	}
+	for _, webhookConfig := range cfg.Webhooks {
+		webhook, err := sink.NewWebhook(webhookConfig)
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+		defer webhook.Close()
+		mapper.AddSink(webhook)
+	}
+	for _, hookConfig := range cfg.ConnectionHooks {
+		hook, err := sink.NewConnectionHook(hookConfig)
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+		defer hook.Close()
+		collector.AddConnectionHook(hook)
+	}
+	if cfg.Graphite.Address != "" {
+		carbon := sink.NewGraphite(cfg.Graphite)
+		defer carbon.Close()
+		mapper.AddSink(carbon)
+	}
+
+	values := collector.NewValuesCollector(cfg)
+	prometheus.MustRegister(values)
+	if cfg.Aggregates {
+		prometheus.MustRegister(collector.NewAggregatesCollector(values))
+	}
+	if cfg.ClimateMetrics {
+		prometheus.MustRegister(collector.NewClimateCollector(values))
+	}
+	if len(cfg.RoomActivityTypes) > 0 {
+		prometheus.MustRegister(collector.NewRoomActivityCollector(values, cfg.RoomActivityTypes))
+	}
+	if cfg.StateFile != "" {
+		err = values.RestoreChanges(cfg.StateFile)
+		if err != nil {
+			log.Errorf("Unable to restore the change counters from %s: %v", cfg.StateFile, err)
+			return 1
+		}
+	}
+	prober := server.NewProber(ctx, cfg, miniservers, mapper, values)
+
+	// Start prometheus server
+	listener, err := net.Listen("tcp", cfg.Web.ListenAddress)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	srv := &http.Server{Handler: server.Handler(cfg, miniservers, mapper, values, prober)}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(listener, srv, cfg)
+	}()
+
+	go server.ReloadOnSignal(ctx, mapper)
+	go superviseSystemd(ctx, cfg, miniservers, values)
+	connectionFailed := make(chan error, 1)
+	if cfg.FailAfter > 0 {
+		go failAfter(ctx, cfg.FailAfter, miniservers, connectionFailed)
+	}
+
+	var wg sync.WaitGroup
+	if cfg.Replay != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := collector.ReplayMiniserver(ctx, cfg, cfg.Replay, mapper, values)
+			if err != nil {
+				log.Errorf("Unable to replay %s: %v", cfg.Replay, err)
+				stop()
+			}
+		}()
+	}
+	if cfg.StateFile != "" && cfg.StateSaveInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			values.PersistChanges(ctx, cfg.StateFile, cfg.StateSaveInterval)
+		}()
+	}
+	if cfg.RemoteWrite.URL != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sink.NewRemoteWrite(cfg.RemoteWrite, server.Gatherer(cfg)).Run(ctx)
+		}()
+	}
+	if cfg.OTLP.Endpoint != "" {
+		otlp, err := sink.NewOTLP(cfg.OTLP, server.Gatherer(cfg))
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			otlp.Run(ctx)
+		}()
+	}
+	for _, miniserver := range miniservers {
+		wg.Add(1)
+		go func(miniserver config.MiniserverConfig) {
+			defer wg.Done()
+			collector.RunMiniserver(ctx, cfg, miniserver, mapper, values)
+		}(miniserver)
+	}
+
+	code := 0
+	select {
+	case <-ctx.Done():
+		log.Infof("Shutting Down")
+	case err := <-serverErr:
+		log.Errorf("HTTP server failed: %v", err)
+		stop()
+		code = 1
+	case err := <-connectionFailed:
+		log.Error(err)
+		stop()
+		code = 1
+	}
+
+	sdNotify("STOPPING=1")
+	// A connection stuck on an unresponsive Miniserver must not keep the exporter from exiting
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		prober.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		log.Warnf("Connections still not closed after %s, exiting anyway", shutdownTimeout)
+	}
+
+	if cfg.StateFile != "" {
+		err = values.SaveChanges(cfg.StateFile)
+		if err != nil {
+			log.Errorf("Unable to save the change counters to %s: %v", cfg.StateFile, err)
+			code = 1
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Errorf("Unable to shut down the HTTP server: %v", err)
+		code = 1
+	}
+
+	return code
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// sensorStates are the states of sensors that should see events regularly, by control type
+var sensorStates = map[string][]string{
+	"IRoomControllerV2": {"tempActual"},
+	"IRoomController":   {"tempActual"},
+	"InfoOnlyAnalog":    {"value"},
+}
+
+type ruleGroups struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// genRules prints Prometheus alerting rules for the exporter, the
+// Miniserver connections and the sensors and alarms of the structure files
+func genRules(ctx context.Context, cfg *config.Config) int {
+	controls, err := mapControls(ctx, cfg)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	out, err := yaml.Marshal(alertRules(controls, cfg))
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	_, err = os.Stdout.Write(out)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	return 0
+}
+
+// foundStates returns the types and states of the exported states the kinds select
+func foundStates(controls []collector.ControlStatus, kinds map[string][]string) (map[string]bool, map[string]bool) {
+	types, states := make(map[string]bool), make(map[string]bool)
+	for _, control := range controls {
+		for _, state := range control.States {
+			if state.Exported && contains(kinds[control.Type], state.Name) {
+				types[control.Type] = true
+				states[state.Name] = true
+			}
+		}
+	}
+	return types, states
+}
+
+func alertRules(controls []collector.ControlStatus, cfg *config.Config) ruleGroups {
+	prefix := cfg.Metrics.Prefix
+	critical := map[string]string{"severity": "critical"}
+	warning := map[string]string{"severity": "warning"}
+
+	rules := []rule{
+		{
+			Alert:       "LoxoneExporterDown",
+			Expr:        fmt.Sprintf(`up{job=%s} == 0`, strconv.Quote(cfg.Rules.Job)),
+			For:         "5m",
+			Labels:      critical,
+			Annotations: map[string]string{"summary": "The Loxone exporter {{ $labels.instance }} can't be scraped"},
+		},
+		{
+			Alert:       "LoxoneMiniserverDisconnected",
+			Expr:        prefix + "_connected == 0",
+			For:         "5m",
+			Labels:      critical,
+			Annotations: map[string]string{"summary": "The exporter lost the connection to the Miniserver {{ $labels.miniserver }}"},
+		},
+	}
+
+	if types, states := foundStates(controls, sensorStates); len(types) > 0 {
+		selector := fmt.Sprintf(`{type=~%s,state=~%s}`, strconv.Quote(alternatives(types)), strconv.Quote(alternatives(states)))
+		staleAfter := model.Duration(cfg.Rules.StaleAfter)
+		expr := fmt.Sprintf("changes(%s_values%s[%s]) == 0", prefix, selector, staleAfter)
+		if cfg.StateTimestamps {
+			expr = fmt.Sprintf("time() - %s_state_last_change_timestamp_seconds%s > %d", prefix, selector, int64(cfg.Rules.StaleAfter/time.Second))
+		}
+		rules = append(rules, rule{
+			Alert:  "LoxoneSensorStale",
+			Expr:   expr,
+			For:    "15m",
+			Labels: warning,
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("{{ $labels.control }} in {{ $labels.room }} didn't change for %s", staleAfter),
+			},
+		})
+	}
+
+	if types, _ := foundStates(controls, map[string][]string{"SmokeAlarm": {"level"}}); len(types) > 0 {
+		rules = append(rules, rule{
+			Alert:       "LoxoneFireOrWaterAlarm",
+			Expr:        prefix + `_values{type="SmokeAlarm",state="level"} > 0`,
+			Labels:      critical,
+			Annotations: map[string]string{"summary": "{{ $labels.control }} in {{ $labels.room }} raised an alarm"},
+		})
+	}
+
+	if types, _ := foundStates(controls, map[string][]string{"Alarm": {"level"}}); len(types) > 0 {
+		rules = append(rules, rule{
+			Alert:       "LoxoneAlarmTriggered",
+			Expr:        prefix + "_alarm_triggered == 1",
+			Labels:      critical,
+			Annotations: map[string]string{"summary": "The alarm zone {{ $labels.zone }} is triggered"},
+		})
+	}
+
+	return ruleGroups{Groups: []ruleGroup{{Name: "loxone", Rules: rules}}}
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// runService runs the exporter until SIGINT or SIGTERM
+func runService(run func(context.Context, context.CancelFunc) int) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return run(ctx, stop)
+}
+
+// installService is only supported on Windows
+func installService(cfg *config.Config) int {
+	log.Error("Services can only be installed on Windows, use systemd elsewhere")
+	return 1
+}
+
+// uninstallService is only supported on Windows
+func uninstallService(cfg *config.Config) int {
+	log.Error("Services can only be uninstalled on Windows")
+	return 1
+}
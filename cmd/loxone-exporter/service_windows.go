@@ -0,0 +1,197 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// eventID is the id of all the events the exporter writes to the event log
+const eventID = 1
+
+// runService runs the exporter as a Windows service when started by the
+// service control manager, or until Ctrl+C from a console
+func runService(run func(context.Context, context.CancelFunc) int) int {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	if !isService {
+		// Windows has no SIGTERM, Ctrl+C and Ctrl+Break are delivered as os.Interrupt
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return run(ctx, stop)
+	}
+
+	// A service has no console, the logs go to the event log
+	log.SetOutput(io.Discard)
+	events, err := eventlog.Open(program)
+	if err == nil {
+		defer events.Close()
+		log.AddHook(&eventLogHook{events: events, formatter: &log.TextFormatter{DisableTimestamp: true, DisableColors: true}})
+	}
+
+	handler := &service{run: run}
+	err = svc.Run(program, handler)
+	if err != nil {
+		log.Errorf("Unable to run the service: %v", err)
+		return 1
+	}
+	return handler.code
+}
+
+// service runs the exporter for the service control manager
+type service struct {
+	run  func(context.Context, context.CancelFunc) int
+	code int
+}
+
+// Execute runs the exporter until the service is stopped or the exporter
+// exits, its exit code becomes the service specific exit code
+func (s *service) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	done := make(chan int, 1)
+	go func() {
+		done <- s.run(ctx, stop)
+	}()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case s.code = <-done:
+			status <- svc.Status{State: svc.StopPending}
+			return s.code != 0, uint32(s.code)
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				status <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				stop()
+			}
+		}
+	}
+}
+
+// eventLogHook writes the log entries to the Windows event log
+type eventLogHook struct {
+	events    *eventlog.Log
+	formatter log.Formatter
+}
+
+func (h *eventLogHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *eventLogHook) Fire(entry *log.Entry) error {
+	message, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	switch entry.Level {
+	case log.PanicLevel, log.FatalLevel, log.ErrorLevel:
+		return h.events.Error(eventID, string(message))
+	case log.WarnLevel:
+		return h.events.Warning(eventID, string(message))
+	default:
+		return h.events.Info(eventID, string(message))
+	}
+}
+
+// installService registers the executable as a service started at boot
+// with the flags of the command line, and the exporter as an event source
+func installService(cfg *config.Config) int {
+	executable, err := os.Executable()
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	manager, err := mgr.Connect()
+	if err != nil {
+		log.Errorf("Unable to connect to the service control manager: %v", err)
+		return 1
+	}
+	defer manager.Disconnect()
+
+	existing, err := manager.OpenService(program)
+	if err == nil {
+		existing.Close()
+		log.Errorf("Service %s already exists", program)
+		return 1
+	}
+
+	created, err := manager.CreateService(program, executable, mgr.Config{
+		DisplayName: "Loxone Prometheus Exporter",
+		Description: "Exports the states of Loxone Miniservers as Prometheus metrics",
+		StartType:   mgr.StartAutomatic,
+	}, serviceArgs()...)
+	if err != nil {
+		log.Errorf("Unable to create the service: %v", err)
+		return 1
+	}
+	defer created.Close()
+
+	err = eventlog.InstallAsEventCreate(program, eventlog.Error|eventlog.Warning|eventlog.Info)
+	if err != nil {
+		created.Delete()
+		log.Errorf("Unable to register the event source: %v", err)
+		return 1
+	}
+	fmt.Printf("Service %s installed, start it with: sc start %s\n", program, program)
+	return 0
+}
+
+// uninstallService removes the service and the event source
+func uninstallService(cfg *config.Config) int {
+	manager, err := mgr.Connect()
+	if err != nil {
+		log.Errorf("Unable to connect to the service control manager: %v", err)
+		return 1
+	}
+	defer manager.Disconnect()
+
+	installed, err := manager.OpenService(program)
+	if err != nil {
+		log.Errorf("Service %s is not installed", program)
+		return 1
+	}
+	defer installed.Close()
+	err = installed.Delete()
+	if err != nil {
+		log.Errorf("Unable to delete the service: %v", err)
+		return 1
+	}
+	err = eventlog.Remove(program)
+	if err != nil {
+		log.Warnf("Unable to remove the event source: %v", err)
+	}
+	fmt.Printf("Service %s uninstalled\n", program)
+	return 0
+}
+
+// serviceArgs are the arguments of the command line without the subcommand,
+// the service runs with the same flags
+func serviceArgs() []string {
+	args := make([]string, 0, len(os.Args))
+	for _, arg := range os.Args[1:] {
+		if arg != "install-service" {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
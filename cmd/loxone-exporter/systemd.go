@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// sdNotify sends a state to systemd, it does nothing when not started by
+// systemd with Type=notify
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		log.Debugf("Unable to notify systemd: %v", err)
+		return
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	if err != nil {
+		log.Debugf("Unable to notify systemd: %v", err)
+	}
+}
+
+// watchdogInterval is WatchdogSec of the unit, 0 if the watchdog is off
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// ready tells whether every Miniserver is connected with its structure file
+// mapped, like /readyz
+func ready(miniservers []config.MiniserverConfig, values *collector.ValuesCollector) bool {
+	for _, miniserver := range miniservers {
+		if !collector.IsConnected(miniserver.Name) || !values.HasStates(miniserver.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// active tells whether every Miniserver sent an event or answered a keepalive within the window
+func active(miniservers []config.MiniserverConfig, window time.Duration) bool {
+	for _, miniserver := range miniservers {
+		if time.Since(collector.LastActivity(miniserver.Name)) > window {
+			return false
+		}
+	}
+	return true
+}
+
+// superviseSystemd notifies systemd once the exporter is ready and pings
+// its watchdog while the Miniservers are active, until the context is done
+func superviseSystemd(ctx context.Context, cfg *config.Config, miniservers []config.MiniserverConfig, values *collector.ValuesCollector) {
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for !ready(miniservers, values) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+	sdNotify("READY=1")
+	log.Info("Notified systemd of readiness")
+
+	watchdog := watchdogInterval()
+	if watchdog == 0 {
+		return
+	}
+	// Without events a Miniserver only proves it's alive with the keepalive answers
+	keepalive := cfg.KeepaliveInterval
+	if keepalive <= 0 {
+		keepalive = loxone.ProbeInterval
+	}
+	window := watchdog + keepalive
+
+	pings := time.NewTicker(watchdog / 2)
+	defer pings.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pings.C:
+			if active(miniservers, window) {
+				sdNotify("WATCHDOG=1")
+			} else {
+				log.Warnf("Miniserver inactive for longer than %s, not pinging the systemd watchdog", window)
+			}
+		}
+	}
+}
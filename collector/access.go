@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// loxoneEpoch is 2009-01-01, the Miniserver counts dates in seconds from there
+const loxoneEpoch = 1230768000
+
+// intercomControlTypes ring a bell, nfcCodeTouchControlType logs accesses
+var intercomControlTypes = map[string]bool{
+	"Intercom":   true,
+	"IntercomV2": true,
+}
+
+const nfcCodeTouchControlType = "NfcCodeTouch"
+
+var (
+	bellPresses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loxone_intercom_bell_presses_total",
+			Help: "Number of times the bell of the intercom was pressed",
+		},
+		[]string{"control"},
+	)
+	lastBell = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_intercom_last_bell_timestamp_seconds",
+			Help: "Unix timestamp of the last time the bell of the intercom was pressed",
+		},
+		[]string{"control"},
+	)
+	accesses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loxone_access_events_total",
+			Help: "Number of entries added to the access history of the NFC Code Touch",
+		},
+		[]string{"control"},
+	)
+	lastAccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_access_last_event_timestamp_seconds",
+			Help: "Unix timestamp of the last entry of the access history of the NFC Code Touch",
+		},
+		[]string{"control"},
+	)
+)
+
+// accessHook maps a state of an intercom or NFC Code Touch to the access
+// metrics, "bell" is 1 while the bell rings and "historyDate" is the date
+// of the last history entry. It returns nil for other states.
+func accessHook(controlType string, control string, stateName string) func(float64) {
+	switch {
+	case intercomControlTypes[controlType] && stateName == "bell":
+		bellPresses.WithLabelValues(control)
+		ringing := false
+		return func(value float64) {
+			if value != 0 && !ringing {
+				bellPresses.WithLabelValues(control).Inc()
+				lastBell.WithLabelValues(control).SetToCurrentTime()
+			}
+			ringing = value != 0
+		}
+	case controlType == nfcCodeTouchControlType && stateName == "historyDate":
+		accesses.WithLabelValues(control)
+		var last float64
+		return func(value float64) {
+			if value == 0 {
+				return
+			}
+			// The first event is the date of the last entry before we connected
+			if last != 0 && value != last {
+				accesses.WithLabelValues(control).Inc()
+			}
+			last = value
+			lastAccess.WithLabelValues(control).Set(value + loxoneEpoch)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RoomActivityCollector exports the time of the last change of the
+// activity controls of every room, e.g. presence detectors and switches
+type RoomActivityCollector struct {
+	values   *ValuesCollector
+	types    map[string]bool
+	activity *prometheus.Desc
+}
+
+// NewRoomActivityCollector creates the collector of the activity of rooms,
+// the changes of controls of these types count as activity
+func NewRoomActivityCollector(values *ValuesCollector, types []string) *RoomActivityCollector {
+	c := &RoomActivityCollector{
+		values:   values,
+		types:    make(map[string]bool, len(types)),
+		activity: prometheus.NewDesc("loxone_room_activity_timestamp_seconds", "Unix timestamp of the last change of an activity control in the room", []string{"miniserver", "room"}, nil),
+	}
+	for _, controlType := range types {
+		c.types[controlType] = true
+	}
+	return c
+}
+
+// Describe implements prometheus.Collector
+func (c *RoomActivityCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.activity
+}
+
+// Collect implements prometheus.Collector
+func (c *RoomActivityCollector) Collect(ch chan<- prometheus.Metric) {
+	type room struct{ miniserver, name string }
+	activity := make(map[room]float64)
+
+	for _, state := range c.values.allStates() {
+		labels := *state.labels
+		if !c.types[labels["type"]] {
+			continue
+		}
+		state.Lock()
+		lastChange := state.lastChange
+		state.Unlock()
+		if lastChange.IsZero() {
+			continue
+		}
+
+		r := room{labels["miniserver"], labels["room"]}
+		if timestamp := float64(lastChange.UnixNano()) / 1e9; timestamp > activity[r] {
+			activity[r] = timestamp
+		}
+	}
+
+	for r, timestamp := range activity {
+		ch <- prometheus.MustNewConstMetric(c.activity, prometheus.GaugeValue, timestamp, r.miniserver, r.name)
+	}
+}
@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lightStates are the states telling a light is on, by control type
+var lightStates = map[string]string{
+	"Switch":    "active",
+	"Dimmer":    "position",
+	"EIBDimmer": "position",
+}
+
+// isLightCategory tells whether a category is of the lights type
+func isLightCategory(structure *loxone.Structure, cat interface{}) bool {
+	uuid, ok := cat.(string)
+	if !ok {
+		return false
+	}
+	category, ok := structure.Cats[uuid]
+	return ok && category.Type == "lights"
+}
+
+// isLightState tells whether a state of a control in a category of lights
+// tells the light is on
+func isLightState(controlType string, state string) bool {
+	return lightStates[controlType] == state
+}
+
+// AggregatesCollector sums the states of the ValuesCollector by room and
+// category, for users without recording rules
+type AggregatesCollector struct {
+	values        *ValuesCollector
+	roomPower     *prometheus.Desc
+	categoryPower *prometheus.Desc
+	roomLights    *prometheus.Desc
+}
+
+// NewAggregatesCollector creates the collector of the aggregates of values
+func NewAggregatesCollector(values *ValuesCollector) *AggregatesCollector {
+	return &AggregatesCollector{
+		values:        values,
+		roomPower:     prometheus.NewDesc("loxone_room_power_watts", "Sum of the power states of the room in watts", []string{"miniserver", "room"}, nil),
+		categoryPower: prometheus.NewDesc("loxone_category_power_watts", "Sum of the power states of the category in watts", []string{"miniserver", "cat"}, nil),
+		roomLights:    prometheus.NewDesc("loxone_room_lights_on", "Number of lights on in the room", []string{"miniserver", "room"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *AggregatesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.roomPower
+	ch <- c.categoryPower
+	ch <- c.roomLights
+}
+
+// Collect implements prometheus.Collector
+func (c *AggregatesCollector) Collect(ch chan<- prometheus.Metric) {
+	type group struct{ miniserver, name string }
+	roomPower := make(map[group]float64)
+	categoryPower := make(map[group]float64)
+	roomLights := make(map[group]float64)
+
+	for _, state := range c.values.allStates() {
+		state.Lock()
+		lastEvent, value := state.lastEvent, state.value
+		state.Unlock()
+		if lastEvent.IsZero() {
+			continue
+		}
+
+		labels := *state.labels
+		room := group{labels["miniserver"], labels["room"]}
+		if state.unit == watts || state.unit == kilowatts {
+			roomPower[room] += value * state.unit.scale
+			categoryPower[group{labels["miniserver"], labels["cat"]}] += value * state.unit.scale
+		}
+		if state.light {
+			lights := roomLights[room]
+			if value > 0 {
+				lights++
+			}
+			roomLights[room] = lights
+		}
+	}
+
+	for g, value := range roomPower {
+		ch <- prometheus.MustNewConstMetric(c.roomPower, prometheus.GaugeValue, value, g.miniserver, g.name)
+	}
+	for g, value := range categoryPower {
+		ch <- prometheus.MustNewConstMetric(c.categoryPower, prometheus.GaugeValue, value, g.miniserver, g.name)
+	}
+	for g, value := range roomLights {
+		ch <- prometheus.MustNewConstMetric(c.roomLights, prometheus.GaugeValue, value, g.miniserver, g.name)
+	}
+}
@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	alarmControlType      = "Alarm"
+	smokeAlarmControlType = "SmokeAlarm"
+)
+
+var (
+	alarmArmed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_alarm_armed",
+			Help: "Whether the alarm zone is armed",
+		},
+		[]string{"zone"},
+	)
+	alarmTriggered = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_alarm_triggered",
+			Help: "Whether the alarm zone is triggered",
+		},
+		[]string{"zone"},
+	)
+	alarmLevel = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_alarm_level",
+			Help: "Current level of the alarm zone, 0 when it isn't triggered",
+		},
+		[]string{"zone"},
+	)
+	alarmLastTrigger = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_alarm_last_trigger_timestamp_seconds",
+			Help: "Unix timestamp of the last time the alarm zone was triggered",
+		},
+		[]string{"zone"},
+	)
+	alarmTriggeredTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loxone_alarm_triggered_total",
+			Help: "Number of times the alarm zone was triggered",
+		},
+		[]string{"zone"},
+	)
+)
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// isAlarmControl tells whether a control type is exported by the security metrics
+func isAlarmControl(controlType string) bool {
+	return controlType == alarmControlType || controlType == smokeAlarmControlType
+}
+
+// alarmHook maps a state of an alarm or smoke alarm control to the security
+// metrics, "armed" is 0/1 and "level" is the alarm level, anything above 0
+// means triggered. It returns nil for states not relevant to the security metrics.
+func alarmHook(controlType string, zone string, stateName string) func(float64) {
+	switch {
+	case stateName == "armed" && controlType == alarmControlType:
+		alarmArmed.WithLabelValues(zone)
+		return func(value float64) {
+			alarmArmed.WithLabelValues(zone).Set(boolValue(value != 0))
+		}
+	case stateName == "level":
+		alarmTriggered.WithLabelValues(zone)
+		alarmTriggeredTotal.WithLabelValues(zone)
+		alarmLevel.WithLabelValues(zone)
+		triggered := false
+		return func(value float64) {
+			if value > 0 && !triggered {
+				alarmTriggeredTotal.WithLabelValues(zone).Inc()
+				alarmLastTrigger.WithLabelValues(zone).SetToCurrentTime()
+			}
+			triggered = value > 0
+			alarmTriggered.WithLabelValues(zone).Set(boolValue(triggered))
+			alarmLevel.WithLabelValues(zone).Set(value)
+		}
+	}
+	return nil
+}
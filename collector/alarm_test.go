@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+// alarmStructure has a burglar alarm zone and a smoke alarm
+const alarmStructure = `{
+	"lastModified": "2024-01-01 00:00:00",
+	"rooms": {"r1": {"name": "Hall", "uuid": "r1"}},
+	"cats": {"c1": {"name": "Security", "uuid": "c1", "type": "undefined"}},
+	"controls": {
+		"a1": {"name": "House", "type": "Alarm", "room": "r1", "cat": "c1",
+			"states": {"armed": "s-armed", "level": "s-level", "armedDelay": "s-delay"}},
+		"a2": {"name": "Kitchen smoke", "type": "SmokeAlarm", "room": "r1", "cat": "c1",
+			"states": {"level": "s-smoke"}}
+	}
+}`
+
+func TestAlarmMetrics(t *testing.T) {
+	states := buildStates(t, &config.Config{SecurityMetrics: true}, alarmStructure)
+	if len(states["s-delay"].hooks) != 0 {
+		t.Error("armedDelay has a security hook")
+	}
+
+	states["s-armed"].update(1)
+	if armed := metricValue(t, alarmArmed.WithLabelValues("House")); armed != 1 {
+		t.Errorf("loxone_alarm_armed is %v, want 1", armed)
+	}
+
+	// A level above 0 is a trigger, counted once until the level drops again
+	for _, level := range []float64{0, 2, 3, 0, 1} {
+		states["s-level"].update(level)
+	}
+	if triggered := metricValue(t, alarmTriggeredTotal.WithLabelValues("House")); triggered != 2 {
+		t.Errorf("loxone_alarm_triggered_total is %v, want 2", triggered)
+	}
+	if triggered := metricValue(t, alarmTriggered.WithLabelValues("House")); triggered != 1 {
+		t.Errorf("loxone_alarm_triggered is %v, want 1", triggered)
+	}
+	if level := metricValue(t, alarmLevel.WithLabelValues("House")); level != 1 {
+		t.Errorf("loxone_alarm_level is %v, want 1", level)
+	}
+	if last := metricValue(t, alarmLastTrigger.WithLabelValues("House")); last == 0 {
+		t.Error("loxone_alarm_last_trigger_timestamp_seconds isn't set")
+	}
+
+	states["s-smoke"].update(0)
+	if triggered := metricValue(t, alarmTriggered.WithLabelValues("Kitchen smoke")); triggered != 0 {
+		t.Errorf("loxone_alarm_triggered of the smoke alarm is %v, want 0", triggered)
+	}
+	if triggered := metricValue(t, alarmTriggeredTotal.WithLabelValues("Kitchen smoke")); triggered != 0 {
+		t.Errorf("loxone_alarm_triggered_total of the smoke alarm is %v, want 0", triggered)
+	}
+}
@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Endpoints of the Miniserver API the exporter requests
+const (
+	endpointStructure        = "structure"
+	endpointStructureVersion = "structure_version"
+	endpointControl          = "control"
+	endpointSecured          = "secured_details"
+	endpointSystem           = "system"
+	endpointInfo             = "info"
+	endpointClock            = "clock"
+	endpointToken            = "token"
+)
+
+var (
+	apiRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "loxone_api_request_duration_seconds",
+			Help:    "Duration of the requests to the Miniserver API",
+			Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"miniserver", "endpoint"},
+	)
+	apiRequestErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loxone_api_request_errors_total",
+			Help: "Number of failed requests to the Miniserver API",
+		},
+		[]string{"miniserver", "endpoint"},
+	)
+)
+
+// request calls the Miniserver API and observes its duration and errors
+func (s *session) request(endpoint string, call func() error) error {
+	start := time.Now()
+	err := call()
+	apiRequestDuration.WithLabelValues(s.miniserver.Name, endpoint).Observe(time.Since(start).Seconds())
+	if err != nil {
+		apiRequestErrors.WithLabelValues(s.miniserver.Name, endpoint).Inc()
+	} else {
+		// Make the error counter of the endpoint visible before its first error
+		apiRequestErrors.WithLabelValues(s.miniserver.Name, endpoint)
+	}
+	return err
+}
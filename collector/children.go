@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+// defaultArrayStateNames name the elements of array states the Loxone
+// structure file documents, by control type and state
+var defaultArrayStateNames = []config.ArrayStateNames{
+	{
+		Type:  "IRoomController",
+		State: "temperatures",
+		Names: []string{
+			"economy",
+			"comfort_heating",
+			"comfort_cooling",
+			"empty_house",
+			"heat_protection",
+			"increased_heat",
+			"party",
+			"manual",
+		},
+	},
+}
+
+// childName returns the name of an element of an array state, the
+// configured names win over the defaults and the index is the fallback
+func childName(configured []config.ArrayStateNames, controlType string, state string, index int) string {
+	for _, names := range [][]config.ArrayStateNames{configured, defaultArrayStateNames} {
+		for _, n := range names {
+			if n.Type == controlType && n.State == state && index < len(n.Names) && n.Names[index] != "" {
+				return n.Names[index]
+			}
+		}
+	}
+	return strconv.Itoa(index)
+}
@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var climateLabelNames = []string{"miniserver", "room", "control"}
+
+var (
+	roomTemperature = prometheus.NewDesc("loxone_room_temperature_celsius",
+		"Temperature measured by the room controller", climateLabelNames, nil)
+	roomTargetTemperature = prometheus.NewDesc("loxone_room_target_temperature_celsius",
+		"Temperature the room controller is aiming at", climateLabelNames, nil)
+	roomOperatingMode = prometheus.NewDesc("loxone_room_operating_mode",
+		"Operating mode of the room controller, as numbered by Loxone", climateLabelNames, nil)
+	roomWindowOpen = prometheus.NewDesc("loxone_room_window_open",
+		"Whether the room controller saw a window open", climateLabelNames, nil)
+)
+
+// climateStates are the metrics of the states of room controllers, by
+// control type and state
+var climateStates = map[string]map[string]*prometheus.Desc{
+	"IRoomControllerV2": {
+		"tempActual":    roomTemperature,
+		"tempTarget":    roomTargetTemperature,
+		"operatingMode": roomOperatingMode,
+		"openWindow":    roomWindowOpen,
+	},
+	"IRoomController": {
+		"tempActual": roomTemperature,
+		"tempTarget": roomTargetTemperature,
+		"mode":       roomOperatingMode,
+		"openWindow": roomWindowOpen,
+	},
+}
+
+// ClimateCollector exports the states of room controllers under names
+// telling what they are, by room
+type ClimateCollector struct {
+	values *ValuesCollector
+}
+
+// NewClimateCollector creates the collector of the room controller states of values
+func NewClimateCollector(values *ValuesCollector) *ClimateCollector {
+	return &ClimateCollector{values: values}
+}
+
+// Describe implements prometheus.Collector
+func (c *ClimateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- roomTemperature
+	ch <- roomTargetTemperature
+	ch <- roomOperatingMode
+	ch <- roomWindowOpen
+}
+
+// Collect implements prometheus.Collector
+func (c *ClimateCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, state := range c.values.allStates() {
+		labels := *state.labels
+		if labels["subcontrol"] != "" {
+			continue
+		}
+		desc, ok := climateStates[labels["type"]][labels["state"]]
+		if !ok {
+			continue
+		}
+		state.Lock()
+		lastEvent, value := state.lastEvent, state.value
+		state.Unlock()
+		if lastEvent.IsZero() {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels["miniserver"], labels["room"], labels["control"])
+	}
+}
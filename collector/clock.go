@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var clockOffset = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "loxone_miniserver_clock_offset_seconds",
+		Help: "Time of the Miniserver clock minus the time of the exporter host, to the second",
+	},
+	[]string{"miniserver"},
+)
+
+// pollClock exports the offset of the Miniserver clock every interval until
+// the context is done
+func (s *session) pollClock(ctx context.Context, lox *loxone.Client, interval time.Duration) {
+	location, err := time.LoadLocation(s.cfg.Clock.Timezone)
+	if err != nil {
+		s.log.Errorf("Unable to read the clock, invalid clock.timezone: %v", err)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var miniserver, local time.Time
+		err := s.request(endpointClock, func() (err error) {
+			miniserver, local, err = lox.Clock(location)
+			return err
+		})
+		if err != nil {
+			s.log.Warnf("Unable to read the clock: %v", err)
+		} else {
+			clockOffset.WithLabelValues(s.miniserver.Name).Set(miniserver.Sub(local).Round(time.Millisecond).Seconds())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Package collector maps the structure file of Miniservers to Prometheus metrics
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ValuesCollector owns the states of every Miniserver and exports their last
+// value and their changes. States without events for longer than their dormancy
+// window are left out of loxone_values until their next event arrives. The states of a
+// disconnected Miniserver aren't exported, their series go stale instead of
+// freezing at the last value.
+type ValuesCollector struct {
+	sync.RWMutex
+	desc           *prometheus.Desc
+	changesDesc    *prometheus.Desc
+	lastChangeDesc *prometheus.Desc
+	lastEventDesc  *prometheus.Desc
+	meterDesc      *prometheus.Desc
+	stateInfoDesc  *prometheus.Desc
+	stateSetDesc   *prometheus.Desc
+	colorDesc      *prometheus.Desc
+	textDesc       *prometheus.Desc
+	defaultDesc    *prometheus.Desc
+	entryDesc      *prometheus.Desc
+	units          map[string]*prometheus.Desc
+	miniservers    map[string]*miniserverStates
+	// restored are the persisted change counters by Miniserver and UUID
+	restored map[string]map[string]persistedState
+	// eventTimestamps exposes the values with the time of their last event
+	eventTimestamps bool
+	// maxLabelLength truncates the texts of loxone_text_info
+	maxLabelLength int
+}
+
+// miniserverStates are the states of a Miniserver, they are kept while it's
+// disconnected so the next map can carry their values over
+type miniserverStates struct {
+	states map[string]*eventMetric
+	// controls are the controls of the structure file
+	controls []*controlEntry
+	active   bool
+}
+
+// NewValuesCollector creates the collector of the per state series
+func NewValuesCollector(cfg *config.Config) *ValuesCollector {
+	c := &ValuesCollector{
+		desc:        prometheus.NewDesc("loxone_values", "Current Value of changes", seriesLabelNames, nil),
+		changesDesc: prometheus.NewDesc("loxone_changes", "Number of changes", seriesLabelNames, nil),
+		meterDesc:   prometheus.NewDesc("loxone_meter_total", "Cumulative readings of meter controls", seriesLabelNames, nil),
+		stateInfoDesc: prometheus.NewDesc("loxone_state_info", "Name of the current value of states with value mappings, always 1",
+			append(append([]string{}, seriesLabelNames...), "state_name"), nil),
+		stateSetDesc: prometheus.NewDesc("loxone_stateset", "Value names of stateset states, 1 for the current one and 0 for the others",
+			append(append([]string{}, seriesLabelNames...), "state_name"), nil),
+		colorDesc: prometheus.NewDesc("loxone_color_channel", "Channels of packed color values, red, green, blue and brightness in percent, temperature in kelvin",
+			append(append([]string{}, seriesLabelNames...), "channel"), nil),
+		textDesc: prometheus.NewDesc("loxone_text_info", "Current text of text states, always 1",
+			append(append([]string{}, seriesLabelNames...), "value"), nil),
+		defaultDesc: prometheus.NewDesc("loxone_daytimer_default_value", "Value of daytimers outside of their schedule entries",
+			seriesLabelNames, nil),
+		entryDesc: prometheus.NewDesc("loxone_daytimer_entry_value", "Value of daytimers during their schedule entries, by operating mode and local start and end time",
+			append(append([]string{}, seriesLabelNames...), "mode", "from", "to"), nil),
+		miniservers:     make(map[string]*miniserverStates),
+		eventTimestamps: cfg.Metrics.EventTimestamps,
+		maxLabelLength:  cfg.MaxLabelLength,
+	}
+	if cfg.LastChangeTimestamp {
+		c.lastChangeDesc = prometheus.NewDesc("loxone_last_change_timestamp_seconds", "Unix timestamp of the last counted change", seriesLabelNames, nil)
+	}
+	if cfg.StateTimestamps {
+		c.lastEventDesc = prometheus.NewDesc("loxone_state_last_change_timestamp_seconds", "Unix timestamp of the last event of the state", seriesLabelNames, nil)
+	}
+	if cfg.TypedMetrics {
+		c.units = unitDescs()
+	}
+	return c
+}
+
+// setStates replaces the exported states of a Miniserver, e.g. after a reconnect
+func (c *ValuesCollector) setStates(miniserver string, states map[string]*eventMetric, controls []*controlEntry) {
+	c.Lock()
+	defer c.Unlock()
+	c.miniservers[miniserver] = &miniserverStates{states: states, controls: controls, active: true}
+}
+
+// deactivate stops exporting the states of a disconnected Miniserver
+func (c *ValuesCollector) deactivate(miniserver string) {
+	c.Lock()
+	defer c.Unlock()
+	if m, ok := c.miniservers[miniserver]; ok {
+		m.active = false
+	}
+}
+
+// Forget drops the states and the connection series of a Miniserver that
+// isn't watched anymore, e.g. an expired /probe target
+func (c *ValuesCollector) Forget(miniserver string) {
+	c.Lock()
+	delete(c.miniservers, miniserver)
+	c.Unlock()
+	forgetConnection(miniserver)
+	forgetWeather(miniserver)
+}
+
+// previousStates returns the last states of a Miniserver, exported or not
+func (c *ValuesCollector) previousStates(miniserver string) map[string]*eventMetric {
+	c.RLock()
+	defer c.RUnlock()
+	if m, ok := c.miniservers[miniserver]; ok {
+		return m.states
+	}
+	return nil
+}
+
+// HasStates tells whether the structure file of a connected Miniserver is mapped
+func (c *ValuesCollector) HasStates(miniserver string) bool {
+	c.RLock()
+	defer c.RUnlock()
+	m, ok := c.miniservers[miniserver]
+	return ok && m.active
+}
+
+// allStates returns the exported states of every Miniserver
+func (c *ValuesCollector) allStates() []*eventMetric {
+	c.RLock()
+	defer c.RUnlock()
+
+	result := make([]*eventMetric, 0)
+	for _, m := range c.miniservers {
+		if !m.active {
+			continue
+		}
+		for _, state := range m.states {
+			result = append(result, state)
+		}
+	}
+	return result
+}
+
+// Describe implements prometheus.Collector
+func (c *ValuesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+	ch <- c.changesDesc
+	ch <- c.meterDesc
+	ch <- c.stateInfoDesc
+	ch <- c.stateSetDesc
+	ch <- c.colorDesc
+	ch <- c.textDesc
+	ch <- c.defaultDesc
+	ch <- c.entryDesc
+	if c.lastChangeDesc != nil {
+		ch <- c.lastChangeDesc
+	}
+	if c.lastEventDesc != nil {
+		ch <- c.lastEventDesc
+	}
+	for _, desc := range c.units {
+		ch <- desc
+	}
+}
+
+// series is a snapshot of the states exported with the same labels
+type series struct {
+	labelValues []string
+	state       *eventMetric
+	value       float64
+	changes     float64
+	lastEvent   time.Time
+	lastChange  time.Time
+	reset       time.Time
+}
+
+// merge adds a state to the series, its value is the one of the last event
+// and its changes the sum of the changes of its states
+func (s *series) merge(state *eventMetric, value float64, changes float64, lastEvent, lastChange, reset time.Time) {
+	s.changes += changes
+	if lastChange.After(s.lastChange) {
+		s.lastChange = lastChange
+	}
+	if s.state == nil || lastEvent.After(s.lastEvent) {
+		s.state, s.value, s.lastEvent, s.reset = state, value, lastEvent, reset
+	}
+}
+
+// seriesLabelValues returns the values of seriesLabelNames
+func seriesLabelValues(labels prometheus.Labels) []string {
+	values := make([]string, 0, len(seriesLabelNames))
+	for _, name := range seriesLabelNames {
+		values = append(values, labels[name])
+	}
+	return values
+}
+
+// series returns the exported states by label values, the states left
+// with the same labels by --metrics.labels or labeldrop are merged
+func (c *ValuesCollector) series() []*series {
+	result := make([]*series, 0)
+	byKey := make(map[string]*series)
+	for _, state := range c.allStates() {
+		state.Lock()
+		lastEvent, value, reset := state.lastEvent, state.value, state.reset
+		changes, lastChange := state.changes, state.lastChange
+		state.Unlock()
+
+		if lastEvent.IsZero() {
+			continue
+		}
+
+		key := labelsKey(*state.labels)
+		s, ok := byKey[key]
+		if !ok {
+			s = &series{labelValues: seriesLabelValues(*state.labels)}
+			byKey[key] = s
+			result = append(result, s)
+		}
+		s.merge(state, value, changes, lastEvent, lastChange, reset)
+	}
+	return result
+}
+
+// Collect implements prometheus.Collector
+func (c *ValuesCollector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+
+	for _, s := range c.series() {
+		state, value, reset, labelValues := s.state, s.value, s.reset, s.labelValues
+
+		if s.changes > 0 {
+			ch <- prometheus.MustNewConstMetric(c.changesDesc, prometheus.CounterValue, s.changes, labelValues...)
+		}
+		if c.lastChangeDesc != nil && !s.lastChange.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.lastChangeDesc, prometheus.GaugeValue, float64(s.lastChange.UnixNano())/1e9, labelValues...)
+		}
+		if c.lastEventDesc != nil {
+			ch <- prometheus.MustNewConstMetric(c.lastEventDesc, prometheus.GaugeValue, float64(s.lastEvent.UnixNano())/1e9, labelValues...)
+		}
+
+		if state.dormancyWindow > 0 && now.Sub(s.lastEvent) > state.dormancyWindow {
+			continue
+		}
+
+		// sample sends a metric of the value, at the time of its event with --metrics.event-timestamps
+		sample := func(metric prometheus.Metric) {
+			if c.eventTimestamps {
+				metric = prometheus.NewMetricWithTimestamp(s.lastEvent, metric)
+			}
+			ch <- metric
+		}
+
+		sample(prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, value, labelValues...))
+		if state.meter {
+			if reset.IsZero() {
+				sample(prometheus.MustNewConstMetric(c.meterDesc, prometheus.CounterValue, value, labelValues...))
+			} else {
+				sample(prometheus.MustNewConstMetricWithCreatedTimestamp(c.meterDesc, prometheus.CounterValue, value, reset, labelValues...))
+			}
+		}
+		if name, ok := valueName(state.valueNames, value); ok {
+			sample(prometheus.MustNewConstMetric(c.stateInfoDesc, prometheus.GaugeValue, 1, append(labelValues, name)...))
+		}
+		if state.stateSet {
+			for _, name := range state.valueNames {
+				current := 0.0
+				if name.Value == value {
+					current = 1
+				}
+				sample(prometheus.MustNewConstMetric(c.stateSetDesc, prometheus.GaugeValue, current, append(labelValues, name.Name)...))
+			}
+		}
+		if state.color != "" {
+			for _, channel := range colorChannels(state.color, value) {
+				sample(prometheus.MustNewConstMetric(c.colorDesc, prometheus.GaugeValue, channel.value, append(labelValues, channel.name)...))
+			}
+		}
+		if c.units != nil && state.unit != nil {
+			sample(prometheus.MustNewConstMetric(c.units[state.unit.name], state.unit.valueType, value*state.unit.scale, labelValues...))
+		}
+	}
+
+	for _, t := range c.texts() {
+		ch <- prometheus.MustNewConstMetric(c.textDesc, prometheus.GaugeValue, 1, append(t.labelValues, truncateLabel(t.text, c.maxLabelLength))...)
+		if t.color != "" {
+			for _, channel := range textColorChannels(t.text) {
+				ch <- prometheus.MustNewConstMetric(c.colorDesc, prometheus.GaugeValue, channel.value, append(t.labelValues, channel.name)...)
+			}
+		}
+	}
+
+	for _, s := range c.schedules() {
+		ch <- prometheus.MustNewConstMetric(c.defaultDesc, prometheus.GaugeValue, s.schedule.defaultValue, s.labelValues...)
+		for _, entry := range s.schedule.entries {
+			ch <- prometheus.MustNewConstMetric(c.entryDesc, prometheus.GaugeValue, entry.value, append(s.labelValues, entry.mode, entry.from, entry.to)...)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+// Encodings of packed color values
+const (
+	// colorRGB packs red, green and blue in percent as BBBGGGRRR
+	colorRGB = "rgb"
+	// colorLumitech packs brightness in percent and the color temperature in
+	// kelvin as 2BBBTTTT
+	colorLumitech = "lumitech"
+	// colorAuto tells the two apart by the leading 2 of Lumitech values
+	colorAuto = "auto"
+)
+
+// lumitechBase is the offset of Lumitech values
+const lumitechBase = 200000000
+
+// colorChannel is a value unpacked from a color
+type colorChannel struct {
+	name  string
+	value float64
+}
+
+// colorOf returns the encoding of the packed colors of a state, empty for
+// plain values
+func colorOf(states []config.ColorState, controlType string, controlName string, state string) string {
+	for _, color := range states {
+		if color.State != "" && color.State != state {
+			continue
+		}
+		if color.Type != "" && color.Type != controlType {
+			continue
+		}
+		if color.Control != "" && color.Control != controlName {
+			continue
+		}
+		if color.Encoding == "" {
+			return colorAuto
+		}
+		return color.Encoding
+	}
+	return ""
+}
+
+// colorChannels unpacks a color value, nil if it isn't one of the encoding
+func colorChannels(encoding string, value float64) []colorChannel {
+	if value < 0 || value != math.Trunc(value) {
+		return nil
+	}
+	packed := int64(value)
+	if encoding == colorAuto {
+		encoding = colorRGB
+		if packed >= lumitechBase {
+			encoding = colorLumitech
+		}
+	}
+
+	switch encoding {
+	case colorRGB:
+		if packed >= 1000000000 {
+			return nil
+		}
+		return []colorChannel{
+			{"red", float64(packed % 1000)},
+			{"green", float64(packed / 1000 % 1000)},
+			{"blue", float64(packed / 1000000)},
+		}
+	case colorLumitech:
+		if packed < lumitechBase || packed >= 2*lumitechBase {
+			return nil
+		}
+		packed -= lumitechBase
+		return []colorChannel{
+			{"brightness", float64(packed / 10000)},
+			{"temperature", float64(packed % 10000)},
+		}
+	}
+	return nil
+}
+
+// textColorChannels unpacks the text colors of color pickers, hsv(h,s,v) into
+// red, green and blue in percent and temp(brightness,kelvin) into brightness
+// and temperature, nil for other texts
+func textColorChannels(text string) []colorChannel {
+	var h, s, v float64
+	if n, _ := fmt.Sscanf(text, "hsv(%g,%g,%g)", &h, &s, &v); n == 3 {
+		r, g, b := hsvToRGB(h, s/100, v/100)
+		return []colorChannel{
+			{"red", math.Round(r * 100)},
+			{"green", math.Round(g * 100)},
+			{"blue", math.Round(b * 100)},
+		}
+	}
+	var brightness, temperature float64
+	if n, _ := fmt.Sscanf(text, "temp(%g,%g)", &brightness, &temperature); n == 2 {
+		return []colorChannel{
+			{"brightness", brightness},
+			{"temperature", temperature},
+		}
+	}
+	return nil
+}
+
+// hsvToRGB converts a hue in degrees and a saturation and value from 0 to 1
+func hsvToRGB(h, s, v float64) (float64, float64, float64) {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	chroma := v * s
+	x := chroma * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - chroma
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = chroma, x, 0
+	case h < 120:
+		r, g, b = x, chroma, 0
+	case h < 180:
+		r, g, b = 0, chroma, x
+	case h < 240:
+		r, g, b = 0, x, chroma
+	case h < 300:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+	return r + m, g + m, b + m
+}
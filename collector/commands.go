@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+)
+
+// ErrUnknownControl is returned for commands to a control no connected
+// Miniserver has
+var ErrUnknownControl = errors.New("no connected Miniserver has this control")
+
+// commandTarget is a connected Miniserver and the controls of its structure file
+type commandTarget struct {
+	lox      *loxone.Client
+	controls map[string]bool
+}
+
+// commandTargets are the connected Miniservers by name
+var commandTargets = struct {
+	sync.RWMutex
+	miniservers map[string]*commandTarget
+}{miniservers: make(map[string]*commandTarget)}
+
+// setCommandTarget takes the controls of the structure file of a connected Miniserver
+func setCommandTarget(miniserver string, lox *loxone.Client, structure *loxone.Structure) {
+	controls := make(map[string]bool, len(structure.Controls))
+	for uuid := range structure.Controls {
+		controls[uuid] = true
+	}
+	commandTargets.Lock()
+	defer commandTargets.Unlock()
+	commandTargets.miniservers[miniserver] = &commandTarget{lox: lox, controls: controls}
+}
+
+// removeCommandTarget forgets a Miniserver once it's disconnected
+func removeCommandTarget(miniserver string) {
+	commandTargets.Lock()
+	defer commandTargets.Unlock()
+	delete(commandTargets.miniservers, miniserver)
+}
+
+// SendCommand sends a command to a control, on the connected Miniserver
+// whose structure file has it, and returns the Miniserver and its answer
+func SendCommand(uuid string, command string) (string, string, error) {
+	commandTargets.RLock()
+	var miniserver string
+	var target *commandTarget
+	for name, candidate := range commandTargets.miniservers {
+		if candidate.controls[uuid] {
+			miniserver, target = name, candidate
+			break
+		}
+	}
+	commandTargets.RUnlock()
+	if target == nil {
+		return "", "", ErrUnknownControl
+	}
+
+	value, err := target.lox.ControlCommand(uuid, command)
+	return miniserver, value, err
+}
@@ -0,0 +1,138 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	up = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_up",
+			Help: "Whether the Miniserver connection is up",
+		},
+		[]string{"miniserver"},
+	)
+	reconnects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loxone_websocket_reconnects_total",
+			Help: "Number of reconnections to the Miniserver",
+		},
+		[]string{"miniserver"},
+	)
+)
+
+// connectionState drives loxone_connected and loxone_up, for loxone_up a lost
+// connection is only reported once it has been down for longer than the grace period
+type connectionState struct {
+	sync.Mutex
+	miniserver string
+	grace      time.Duration
+	down       *time.Timer
+	connected  bool
+	// lastActivity is the time of the last event or keepalive answer
+	lastActivity time.Time
+}
+
+// connections are the connection states by Miniserver name
+var connections = struct {
+	sync.RWMutex
+	states map[string]*connectionState
+}{states: make(map[string]*connectionState)}
+
+func newConnectionState(miniserver string, grace time.Duration) *connectionState {
+	state := &connectionState{miniserver: miniserver, grace: grace}
+	connections.Lock()
+	connections.states[miniserver] = state
+	connections.Unlock()
+	return state
+}
+
+// forgetConnection drops the connection state and series of a Miniserver
+func forgetConnection(miniserver string) {
+	connections.Lock()
+	state, ok := connections.states[miniserver]
+	delete(connections.states, miniserver)
+	connections.Unlock()
+	if ok {
+		state.Lock()
+		if state.down != nil {
+			state.down.Stop()
+			state.down = nil
+		}
+		state.Unlock()
+	}
+	up.DeleteLabelValues(miniserver)
+	connected.DeleteLabelValues(miniserver)
+}
+
+// IsConnected tells whether the Miniserver is connected, without grace period
+func IsConnected(miniserver string) bool {
+	connections.RLock()
+	state, ok := connections.states[miniserver]
+	connections.RUnlock()
+	if !ok {
+		return false
+	}
+	state.Lock()
+	defer state.Unlock()
+	return state.connected
+}
+
+// LastActivity returns when the Miniserver last sent an event or answered a keepalive
+func LastActivity(miniserver string) time.Time {
+	connections.RLock()
+	state, ok := connections.states[miniserver]
+	connections.RUnlock()
+	if !ok {
+		return time.Time{}
+	}
+	state.Lock()
+	defer state.Unlock()
+	return state.lastActivity
+}
+
+// alive records activity of the Miniserver
+func (c *connectionState) alive() {
+	c.Lock()
+	c.lastActivity = time.Now()
+	c.Unlock()
+}
+
+func (c *connectionState) set(isConnected bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.connected = isConnected
+	connected.WithLabelValues(c.miniserver).Set(boolValue(isConnected))
+	if isConnected {
+		if c.down != nil {
+			c.down.Stop()
+			c.down = nil
+		}
+		up.WithLabelValues(c.miniserver).Set(1)
+		return
+	}
+
+	if c.grace == 0 {
+		up.WithLabelValues(c.miniserver).Set(0)
+		return
+	}
+	if c.down == nil {
+		c.down = time.AfterFunc(c.grace, c.expireGrace)
+	}
+}
+
+// expireGrace drops loxone_up once the grace period is over, unless the
+// Miniserver reconnected while the timer fired
+func (c *connectionState) expireGrace() {
+	c.Lock()
+	defer c.Unlock()
+	if c.connected {
+		return
+	}
+	c.down = nil
+	up.WithLabelValues(c.miniserver).Set(0)
+}
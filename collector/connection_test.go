@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+// upValue reads loxone_up of a Miniserver
+func upValue(t *testing.T, miniserver string) float64 {
+	t.Helper()
+	return metricValue(t, up.WithLabelValues(miniserver))
+}
+
+func TestUpDropsAfterGrace(t *testing.T) {
+	state := newConnectionState("grace-down", 50*time.Millisecond)
+	state.set(true)
+	state.set(false)
+
+	if value := upValue(t, "grace-down"); value != 1 {
+		t.Fatalf("loxone_up is %v within the grace period, want 1", value)
+	}
+	if IsConnected("grace-down") {
+		t.Error("IsConnected is true after the connection was lost")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if value := upValue(t, "grace-down"); value != 0 {
+		t.Errorf("loxone_up is %v after the grace period, want 0", value)
+	}
+}
+
+func TestUpStaysWhenReconnectedWithinGrace(t *testing.T) {
+	state := newConnectionState("grace-blip", 50*time.Millisecond)
+	state.set(true)
+	state.set(false)
+	state.set(true)
+
+	time.Sleep(100 * time.Millisecond)
+	if value := upValue(t, "grace-blip"); value != 1 {
+		t.Errorf("loxone_up is %v after a blip shorter than the grace period, want 1", value)
+	}
+}
+
+func TestUpDropsWithoutGrace(t *testing.T) {
+	state := newConnectionState("no-grace", 0)
+	state.set(true)
+	state.set(false)
+
+	if value := upValue(t, "no-grace"); value != 0 {
+		t.Errorf("loxone_up is %v without grace period, want 0", value)
+	}
+}
+
+func TestUpStaysWhenReconnectedWhileTimerFires(t *testing.T) {
+	state := newConnectionState("grace-race", time.Hour)
+	state.set(true)
+	state.set(false)
+	state.set(true)
+
+	// A timer that fired right before the reconnect runs after it
+	state.expireGrace()
+	if value := upValue(t, "grace-race"); value != 1 {
+		t.Errorf("loxone_up is %v after reconnecting while the grace timer fired, want 1", value)
+	}
+}
@@ -0,0 +1,217 @@
+package collector
+
+import (
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	loxonews "github.com/XciD/loxone-ws"
+)
+
+// controlEntry is a control of the structure file and why it isn't exported
+type controlEntry struct {
+	uuid, name, room, roomUUID, cat, controlType string
+	// filtered is the filter excluding the control, empty if it is exported
+	filtered string
+	// states are the state UUIDs by state name, array elements are suffixed with their index
+	states map[string]string
+}
+
+func newControlEntry(uuid string, control *loxonews.Control, structure *loxone.Structure, arrayNames []config.ArrayStateNames) *controlEntry {
+	entry := &controlEntry{
+		uuid:        uuid,
+		name:        control.Name,
+		room:        structure.RoomName(control.Room),
+		roomUUID:    control.Room,
+		cat:         structure.CatName(control.Cat),
+		controlType: control.Type,
+		states:      make(map[string]string),
+	}
+	for stateName, stateValue := range control.States {
+		switch stateValue := stateValue.(type) {
+		case string:
+			entry.states[stateName] = stateValue
+		case []interface{}:
+			for index, child := range stateValue {
+				if childUUID, ok := child.(string); ok {
+					entry.states[stateName+"-"+childName(arrayNames, control.Type, stateName, index)] = childUUID
+				}
+			}
+		}
+	}
+	return entry
+}
+
+// ControlStatus is a control of the structure file with the current values of its states
+type ControlStatus struct {
+	Miniserver string        `json:"miniserver"`
+	UUID       string        `json:"uuid"`
+	Name       string        `json:"name"`
+	Room       string        `json:"room"`
+	RoomUUID   string        `json:"room_uuid"`
+	Cat        string        `json:"cat"`
+	Type       string        `json:"type"`
+	Filtered   string        `json:"filtered,omitempty"`
+	States     []StateStatus `json:"states"`
+}
+
+// StateStatus is a state of a control, Exported is false if it has no series
+type StateStatus struct {
+	Name       string            `json:"name"`
+	UUID       string            `json:"uuid"`
+	Exported   bool              `json:"exported"`
+	Labels     prometheus.Labels `json:"labels,omitempty"`
+	Value      float64           `json:"value"`
+	LastUpdate time.Time         `json:"last_update"`
+}
+
+// Controls returns all controls of the structure files, by Miniserver and name
+func (c *ValuesCollector) Controls() []ControlStatus {
+	c.RLock()
+	defer c.RUnlock()
+
+	result := make([]ControlStatus, 0)
+	for miniserver, m := range c.miniservers {
+		result = append(result, controlStatuses(miniserver, m.controls, m.states)...)
+	}
+	sortControls(result)
+	return result
+}
+
+// MapControls maps a structure file like the exporter does, without
+// exporting anything, and returns its controls with their labels
+func (m *StateMapper) MapControls(structure *loxone.Structure, miniserver string) []ControlStatus {
+	states, report := m.build(structure, miniserver)
+	result := controlStatuses(miniserver, report.controls, states)
+	sortControls(result)
+	return result
+}
+
+// controlStatuses returns the controls with the states they are mapped to
+func controlStatuses(miniserver string, entries []*controlEntry, states map[string]*eventMetric) []ControlStatus {
+	result := make([]ControlStatus, 0, len(entries))
+	for _, entry := range entries {
+		control := ControlStatus{
+			Miniserver: miniserver,
+			UUID:       entry.uuid,
+			Name:       entry.name,
+			Room:       entry.room,
+			RoomUUID:   entry.roomUUID,
+			Cat:        entry.cat,
+			Type:       entry.controlType,
+			Filtered:   entry.filtered,
+			States:     make([]StateStatus, 0, len(entry.states)),
+		}
+		for stateName, uuid := range entry.states {
+			state := StateStatus{Name: stateName, UUID: uuid}
+			if metric, ok := states[uuid]; ok {
+				metric.Lock()
+				state.Exported, state.Labels = true, *metric.labels
+				state.Value, state.LastUpdate = metric.value, metric.lastEvent
+				metric.Unlock()
+			}
+			control.States = append(control.States, state)
+		}
+		sort.Slice(control.States, func(i, j int) bool {
+			return control.States[i].Name < control.States[j].Name
+		})
+		result = append(result, control)
+	}
+	return result
+}
+
+// sortControls sorts controls by Miniserver and name
+func sortControls(result []ControlStatus) {
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Miniserver != result[j].Miniserver {
+			return result[i].Miniserver < result[j].Miniserver
+		}
+		return result[i].Name < result[j].Name
+	})
+}
+
+// StateValue is the current value of an exported state
+type StateValue struct {
+	Miniserver string    `json:"miniserver"`
+	Control    string    `json:"control"`
+	Room       string    `json:"room"`
+	Cat        string    `json:"cat"`
+	Type       string    `json:"type"`
+	State      string    `json:"state"`
+	Value      float64   `json:"value"`
+	LastUpdate time.Time `json:"last_update"`
+}
+
+// Values returns the current values of the exported states with their labels,
+// states without an event yet are left out
+func (c *ValuesCollector) Values() []StateValue {
+	result := make([]StateValue, 0)
+	for _, state := range c.allStates() {
+		state.Lock()
+		value, lastEvent := state.value, state.lastEvent
+		state.Unlock()
+		if lastEvent.IsZero() {
+			continue
+		}
+
+		labels := *state.labels
+		result = append(result, StateValue{
+			Miniserver: labels["miniserver"],
+			Control:    labels["control"],
+			Room:       labels["room"],
+			Cat:        labels["cat"],
+			Type:       labels["type"],
+			State:      labels["state"],
+			Value:      value,
+			LastUpdate: lastEvent,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Miniserver != b.Miniserver {
+			return a.Miniserver < b.Miniserver
+		}
+		if a.Control != b.Control {
+			return a.Control < b.Control
+		}
+		return a.State < b.State
+	})
+	return result
+}
+
+// MapSummary tells how the structure file of a Miniserver maps to series
+type MapSummary struct {
+	Miniserver string `json:"miniserver"`
+	Controls   int    `json:"controls"`
+	Kept       int    `json:"kept"`
+	// Filtered count the controls and global states left out, by filter
+	Filtered map[string]int `json:"filtered"`
+	// SkippedControls are the controls without a state to map
+	SkippedControls []string `json:"skipped_controls"`
+	// UnsupportedStates count the states that are neither a UUID nor an array of UUIDs, by type.state
+	UnsupportedStates map[string]int `json:"unsupported_states"`
+	DuplicateUUIDs    []string       `json:"duplicate_uuids"`
+	Series            int            `json:"series"`
+}
+
+// Summarize maps a structure file like the exporter does after connecting
+// and tells what came out of it, nothing is exported
+func (m *StateMapper) Summarize(structure *loxone.Structure, miniserver string) MapSummary {
+	_, report := m.build(structure, miniserver)
+	sort.Strings(report.SkippedControls)
+	return MapSummary{
+		Miniserver:        miniserver,
+		Controls:          report.Controls,
+		Kept:              report.Kept,
+		Filtered:          report.Filtered,
+		SkippedControls:   report.SkippedControls,
+		UnsupportedStates: report.UnsupportedStates,
+		DuplicateUUIDs:    report.DuplicateUUIDs,
+		Series:            report.Series,
+	}
+}
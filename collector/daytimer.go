@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/XciD/loxone-ws/events"
+)
+
+// scheduleEntry is an entry of a daytimer schedule, with the name of its
+// operating mode and its times as HH:MM
+type scheduleEntry struct {
+	mode, from, to string
+	value          float64
+}
+
+// daytimerSchedule is the schedule of a daytimer state
+type daytimerSchedule struct {
+	defaultValue float64
+	entries      []scheduleEntry
+}
+
+// newDaytimerSchedule names the entries of a daytimer event with the
+// operating modes of the structure file, entries with the same mode and
+// times are exported once, the last one wins
+func newDaytimerSchedule(daytimer *events.Daytimer, modes map[string]string) *daytimerSchedule {
+	schedule := &daytimerSchedule{defaultValue: daytimer.DefaultValue, entries: make([]scheduleEntry, 0, len(daytimer.Entries))}
+	index := make(map[scheduleEntry]int)
+	for _, entry := range daytimer.Entries {
+		mode := strconv.Itoa(int(entry.Mode))
+		if name, ok := modes[mode]; ok {
+			mode = name
+		}
+		key := scheduleEntry{mode: mode, from: minuteOfDay(entry.From), to: minuteOfDay(entry.To)}
+		if i, ok := index[key]; ok {
+			schedule.entries[i].value = entry.Value
+			continue
+		}
+		index[key] = len(schedule.entries)
+		key.value = entry.Value
+		schedule.entries = append(schedule.entries, key)
+	}
+	return schedule
+}
+
+// minuteOfDay formats minutes after midnight as HH:MM, 1440 is 24:00
+func minuteOfDay(minutes int32) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// setSchedule keeps the schedule of a daytimer event
+func (e *eventMetric) setSchedule(schedule *daytimerSchedule) {
+	e.Lock()
+	defer e.Unlock()
+	e.schedule = schedule
+}
+
+// scheduleSeries is the schedule of the daytimer states exported with the
+// same labels
+type scheduleSeries struct {
+	labelValues []string
+	schedule    *daytimerSchedule
+}
+
+// schedules returns the last schedules of the daytimer states, the first
+// state wins when several are left with the same labels
+func (c *ValuesCollector) schedules() []*scheduleSeries {
+	result := make([]*scheduleSeries, 0)
+	seen := make(map[string]bool)
+	for _, state := range c.allStates() {
+		state.Lock()
+		schedule := state.schedule
+		state.Unlock()
+		if schedule == nil {
+			continue
+		}
+
+		key := labelsKey(*state.labels)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, &scheduleSeries{labelValues: seriesLabelValues(*state.labels), schedule: schedule})
+	}
+	return result
+}
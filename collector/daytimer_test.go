@@ -0,0 +1,28 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/XciD/loxone-ws/events"
+)
+
+func TestDaytimerSchedule(t *testing.T) {
+	daytimer := &events.Daytimer{
+		DefaultValue: 18,
+		Entries: []events.DaytimerEntry{
+			{Mode: 3, From: 360, To: 480, Value: 21},
+			{Mode: 9, From: 0, To: 1440, Value: 19},
+			{Mode: 3, From: 360, To: 480, Value: 22},
+		},
+	}
+	schedule := newDaytimerSchedule(daytimer, map[string]string{"3": "Monday"})
+
+	want := &daytimerSchedule{defaultValue: 18, entries: []scheduleEntry{
+		{mode: "Monday", from: "06:00", to: "08:00", value: 22},
+		{mode: "9", from: "00:00", to: "24:00", value: 19},
+	}}
+	if !reflect.DeepEqual(schedule, want) {
+		t.Errorf("schedule %+v, want %+v", schedule, want)
+	}
+}
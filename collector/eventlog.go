@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+// eventLogLimiter limits the "New event" log lines per control and minute
+type eventLogLimiter struct {
+	sync.Mutex
+	limit  int
+	window time.Time
+	counts map[string]int
+}
+
+func newEventLogLimiter(limit int) *eventLogLimiter {
+	return &eventLogLimiter{limit: limit, counts: make(map[string]int)}
+}
+
+// allow tells whether another event of the control may be logged, a limit
+// of 0 allows all of them
+func (l *eventLogLimiter) allow(control string) bool {
+	if l.limit <= 0 {
+		return true
+	}
+	l.Lock()
+	defer l.Unlock()
+
+	if now := time.Now(); now.Sub(l.window) >= time.Minute {
+		l.window = now
+		l.counts = make(map[string]int)
+	}
+	l.counts[control]++
+	return l.counts[control] <= l.limit
+}
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+// filterFields are the fields a control can be filtered on
+var filterFields = []string{"control", "room", "cat", "type", "uuid"}
+
+// controlFilter decides which controls are mapped, a control must match
+// every include regex and none of the exclude regexes
+type controlFilter struct {
+	include map[string]*regexp.Regexp
+	exclude map[string]*regexp.Regexp
+}
+
+func compileFilter(filter config.FilterConfig, kind string) (map[string]*regexp.Regexp, error) {
+	expressions := map[string]string{
+		"control": filter.Control,
+		"room":    filter.Room,
+		"cat":     filter.Cat,
+		"type":    filter.Type,
+		"uuid":    filter.UUID,
+	}
+
+	result := make(map[string]*regexp.Regexp)
+	for field, expression := range expressions {
+		if expression == "" {
+			continue
+		}
+		regex, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s.%s filter: %v", kind, field, err)
+		}
+		result[field] = regex
+	}
+	return result, nil
+}
+
+func newControlFilter(cfg *config.Config) (*controlFilter, error) {
+	include, err := compileFilter(cfg.Include, "include")
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileFilter(cfg.Exclude, "exclude")
+	if err != nil {
+		return nil, err
+	}
+	return &controlFilter{include: include, exclude: exclude}, nil
+}
+
+// match returns the filter dropping the control, e.g. "exclude.room",
+// or an empty string if the control is kept
+func (f *controlFilter) match(labels map[string]string, uuid string) string {
+	for _, field := range filterFields {
+		value := labels[field]
+		if field == "uuid" {
+			value = uuid
+		}
+
+		if regex, ok := f.include[field]; ok && !regex.MatchString(value) {
+			return "include." + field
+		}
+		if regex, ok := f.exclude[field]; ok && regex.MatchString(value) {
+			return "exclude." + field
+		}
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+// floorMapper derives a floor label from the room name, either from the
+// first capture group of a regex or from a room name prefix
+type floorMapper struct {
+	regex    *regexp.Regexp
+	prefixes []string
+	floors   map[string]string
+	fallback string
+}
+
+func floorsEnabled(cfg *config.Config) bool {
+	return cfg.FloorRegex != "" || len(cfg.FloorPrefixes) > 0
+}
+
+// newFloorMapper returns nil if no floor rule is configured
+func newFloorMapper(cfg *config.Config) (*floorMapper, error) {
+	if !floorsEnabled(cfg) {
+		return nil, nil
+	}
+
+	f := &floorMapper{
+		floors:   make(map[string]string),
+		fallback: cfg.FloorFallback,
+	}
+
+	if cfg.FloorRegex != "" {
+		regex, err := regexp.Compile(cfg.FloorRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid floor regex: %v", err)
+		}
+		if regex.NumSubexp() < 1 {
+			return nil, fmt.Errorf("floor regex %q needs a capture group", cfg.FloorRegex)
+		}
+		f.regex = regex
+	}
+
+	for prefix, floor := range cfg.FloorPrefixes {
+		prefix = strings.ToLower(prefix)
+		f.prefixes = append(f.prefixes, prefix)
+		f.floors[prefix] = floor
+	}
+	// Longest prefix wins
+	sort.Slice(f.prefixes, func(i, j int) bool {
+		return len(f.prefixes[i]) > len(f.prefixes[j])
+	})
+
+	return f, nil
+}
+
+func (f *floorMapper) floor(room string) string {
+	lower := strings.ToLower(room)
+	for _, prefix := range f.prefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return f.floors[prefix]
+		}
+	}
+
+	if f.regex != nil {
+		if match := f.regex.FindStringSubmatch(room); match != nil && match[1] != "" {
+			return match[1]
+		}
+	}
+
+	return f.fallback
+}
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+func TestFloor(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		// floors are the expected floors by room name
+		floors map[string]string
+	}{
+		{
+			name: "regex",
+			cfg:  config.Config{FloorRegex: `^(EG|OG|UG) `, FloorFallback: "unknown"},
+			floors: map[string]string{
+				"EG Wohnzimmer": "EG",
+				"OG Bad":        "OG",
+				"UG Keller":     "UG",
+				"Garten":        "unknown",
+			},
+		},
+		{
+			name: "prefixes",
+			cfg: config.Config{FloorPrefixes: map[string]string{
+				"ground":       "0",
+				"ground floor": "ground",
+				"first":        "1",
+			}},
+			floors: map[string]string{
+				"Ground floor kitchen": "ground",
+				"Groundwork":           "0",
+				"first bedroom":        "1",
+				"Attic":                "",
+			},
+		},
+		{
+			name: "prefixes before regex",
+			cfg: config.Config{
+				FloorRegex:    `^(\d)\.`,
+				FloorPrefixes: map[string]string{"garage": "0"},
+				FloorFallback: "outside",
+			},
+			floors: map[string]string{
+				"2.Office":   "2",
+				"Garage":     "0",
+				"Terrace":    "outside",
+				"3.Storage":  "3",
+				".No number": "outside",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			floors, err := newFloorMapper(&test.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for room, want := range test.floors {
+				if floor := floors.floor(room); floor != want {
+					t.Errorf("floor of %q is %q, want %q", room, floor, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFloorRegexNeedsCaptureGroup(t *testing.T) {
+	_, err := newFloorMapper(&config.Config{FloorRegex: `^EG`})
+	if err == nil {
+		t.Error("a floor regex without capture group is accepted")
+	}
+}
+
+func TestFloorsDisabled(t *testing.T) {
+	floors, err := newFloorMapper(&config.Config{})
+	if err != nil || floors != nil {
+		t.Errorf("got %v, %v without floor rules, want no mapper", floors, err)
+	}
+}
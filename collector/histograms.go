@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var defaultIntervalBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600, 21600, 86400}
+
+var (
+	eventInterval   *prometheus.HistogramVec
+	eventProcessing *prometheus.HistogramVec
+)
+
+// newEventHistograms creates the histograms of the event intervals and
+// processing durations by control type
+func newEventHistograms(cfg *config.Config) {
+	intervalOpts := prometheus.HistogramOpts{
+		Name:    "loxone_event_interval_seconds",
+		Help:    "Time between two events of the same state",
+		Buckets: defaultIntervalBuckets,
+	}
+	processingOpts := prometheus.HistogramOpts{
+		Name:    "loxone_event_processing_duration_seconds",
+		Help:    "Time spent updating the metrics of an event",
+		Buckets: prometheus.ExponentialBuckets(0.00001, 4, 8),
+	}
+	if cfg.NativeHistograms {
+		for _, opts := range []*prometheus.HistogramOpts{&intervalOpts, &processingOpts} {
+			opts.Buckets = nil
+			opts.NativeHistogramBucketFactor = 1.1
+			opts.NativeHistogramMaxBucketNumber = 160
+			opts.NativeHistogramMinResetDuration = time.Hour
+		}
+	}
+
+	eventInterval = prometheus.NewHistogramVec(intervalOpts, []string{"miniserver", "type"})
+	eventProcessing = prometheus.NewHistogramVec(processingOpts, []string{"miniserver", "type"})
+}
@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+// Connection events of the hooks
+const (
+	EventConnect     = "connect"
+	EventDisconnect  = "disconnect"
+	EventAuthFailure = "auth-failure"
+)
+
+// ConnectionEvent is a change of the connection to a Miniserver
+type ConnectionEvent struct {
+	Miniserver string `json:"miniserver"`
+	// Event is connect, disconnect or auth-failure
+	Event string `json:"event"`
+	// Error is why the connection was lost or refused, empty on connect
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// ConnectionHook is told about the connection events of every Miniserver,
+// Notify must not block
+type ConnectionHook interface {
+	Notify(event ConnectionEvent)
+}
+
+// connectionHooks are the hooks of AddConnectionHook
+var connectionHooks = struct {
+	sync.RWMutex
+	hooks []ConnectionHook
+}{}
+
+// AddConnectionHook notifies the hook of the connection events from now on
+func AddConnectionHook(hook ConnectionHook) {
+	connectionHooks.Lock()
+	defer connectionHooks.Unlock()
+	connectionHooks.hooks = append(connectionHooks.hooks, hook)
+}
+
+// notifyConnection hands a connection event to every hook
+func notifyConnection(miniserver string, event string, err error) {
+	connectionHooks.RLock()
+	defer connectionHooks.RUnlock()
+	if len(connectionHooks.hooks) == 0 {
+		return
+	}
+
+	connectionEvent := ConnectionEvent{Miniserver: miniserver, Event: event, Time: time.Now()}
+	if err != nil {
+		connectionEvent.Error = err.Error()
+	}
+	for _, hook := range connectionHooks.hooks {
+		hook.Notify(connectionEvent)
+	}
+}
@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	loxonews "github.com/XciD/loxone-ws"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var miniserverInfo = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "loxone_miniserver_info",
+		Help: "Serial number, firmware version and project of the Miniserver",
+	},
+	[]string{"miniserver", "host", "serial", "firmware", "project_name", "ms_name"},
+)
+
+// apiInfo is the answer of jdev/cfg/api, e.g. {'snr': '50:4F:94:10:00:00', 'version':'10.3.11.27'}
+type apiInfo struct {
+	Serial  string `json:"snr"`
+	Version string `json:"version"`
+}
+
+func parseAPIInfo(value string) (*apiInfo, error) {
+	info := &apiInfo{}
+	err := json.Unmarshal([]byte(strings.Replace(value, "'", "\"", -1)), info)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// infoLabels is what is known about a Miniserver, from jdev/cfg/api and the
+// msInfo of the structure file
+type infoLabels struct {
+	host, serial, firmware, projectName, msName, location string
+}
+
+// infos are the info labels by Miniserver name
+var infos = struct {
+	sync.Mutex
+	labels map[string]*infoLabels
+}{labels: make(map[string]*infoLabels)}
+
+// setInfo updates the info labels of a Miniserver and exports them
+func setInfo(miniserver string, update func(*infoLabels)) {
+	infos.Lock()
+	defer infos.Unlock()
+	labels, ok := infos.labels[miniserver]
+	if !ok {
+		labels = &infoLabels{}
+		infos.labels[miniserver] = labels
+	}
+	update(labels)
+
+	miniserverInfo.DeletePartialMatch(prometheus.Labels{"miniserver": miniserver})
+	miniserverInfo.WithLabelValues(miniserver, labels.host, labels.serial, labels.firmware, labels.projectName, labels.msName).Set(1)
+}
+
+func updateMiniserverInfo(miniserver string, value *loxonews.SimpleValue, host string) {
+	info, err := parseAPIInfo(value.Value)
+	if err != nil {
+		log.Debugf("Unable to parse Miniserver info %q: %v", value.Value, err)
+		return
+	}
+
+	setInfo(miniserver, func(labels *infoLabels) {
+		labels.host, labels.serial, labels.firmware = host, info.Serial, info.Version
+	})
+}
+
+// updateStructureInfo takes the project of the msInfo block of the structure file,
+// and the serial number until jdev/cfg/api told a better formatted one
+func updateStructureInfo(miniserver string, msInfo map[string]interface{}) {
+	text := func(key string) string {
+		if value, ok := msInfo[key]; ok && value != nil {
+			return fmt.Sprint(value)
+		}
+		return ""
+	}
+
+	setInfo(miniserver, func(labels *infoLabels) {
+		labels.projectName, labels.msName, labels.location = text("projectName"), text("msName"), text("location")
+		if labels.serial == "" {
+			labels.serial = text("serialNr")
+		}
+	})
+}
+
+// MiniserverLabels returns what is known about a Miniserver as labels, the
+// serial number, project, name and location, empty ones are left out
+func MiniserverLabels(miniserver string) map[string]string {
+	infos.Lock()
+	defer infos.Unlock()
+	result := make(map[string]string)
+	labels, ok := infos.labels[miniserver]
+	if !ok {
+		return result
+	}
+	for name, value := range map[string]string{
+		"serial":       labels.serial,
+		"project_name": labels.projectName,
+		"ms_name":      labels.msName,
+		"location":     labels.location,
+	} {
+		if value != "" {
+			result[name] = value
+		}
+	}
+	return result
+}
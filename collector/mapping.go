@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+// valueNames returns the value names of the first mapping matching the state
+func valueNames(mappings []config.ValueMapping, controlType string, controlName string, state string) []config.ValueName {
+	for _, mapping := range mappings {
+		if mapping.State != state {
+			continue
+		}
+		if mapping.Type != "" && mapping.Type != controlType {
+			continue
+		}
+		if mapping.Control != "" && mapping.Control != controlName {
+			continue
+		}
+		return mapping.Values
+	}
+	return nil
+}
+
+// transformOf returns the first transform matching the state, nil if none does
+func transformOf(transforms []config.Transform, controlType string, controlName string, state string) *config.Transform {
+	for i, transform := range transforms {
+		if transform.State != "" && transform.State != state {
+			continue
+		}
+		if transform.Type != "" && transform.Type != controlType {
+			continue
+		}
+		if transform.Control != "" && transform.Control != controlName {
+			continue
+		}
+		return &transforms[i]
+	}
+	return nil
+}
+
+// transformValue applies a transform to a value
+func transformValue(transform *config.Transform, value float64) float64 {
+	if transform.Invert {
+		value = 1 - value
+	}
+	if transform.Multiply != nil {
+		value *= *transform.Multiply
+	}
+	value += transform.Add
+	if transform.Min != nil && value < *transform.Min {
+		value = *transform.Min
+	}
+	if transform.Max != nil && value > *transform.Max {
+		value = *transform.Max
+	}
+	return value
+}
+
+const (
+	metricTypeGauge    = "gauge"
+	metricTypeCounter  = "counter"
+	metricTypeStateSet = "stateset"
+)
+
+// booleanNames name the values of stateset states without value mapping
+var booleanNames = []config.ValueName{{Value: 0, Name: "off"}, {Value: 1, Name: "on"}}
+
+// metricTypeOf returns how the first matching override exports the state,
+// empty if none matches
+func metricTypeOf(types []config.MetricType, controlType string, controlName string, state string) string {
+	for _, override := range types {
+		if override.State != "" && override.State != state {
+			continue
+		}
+		if override.Type != "" && override.Type != controlType {
+			continue
+		}
+		if override.Control != "" && override.Control != controlName {
+			continue
+		}
+		return override.As
+	}
+	return ""
+}
+
+// histogramOf tells whether the values of a state are recorded in
+// loxone_value_histogram, all are without a selection
+func histogramOf(selection []config.HistogramState, controlType string, controlName string, state string) bool {
+	if len(selection) == 0 {
+		return true
+	}
+	for _, selected := range selection {
+		if selected.State != "" && selected.State != state {
+			continue
+		}
+		if selected.Type != "" && selected.Type != controlType {
+			continue
+		}
+		if selected.Control != "" && selected.Control != controlName {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// nameOverride returns the name given to a UUID in the config, the keys
+// of maps in the config file are lowercased
+func nameOverride(names map[string]string, uuid string) (string, bool) {
+	name, ok := names[strings.ToLower(uuid)]
+	return name, ok
+}
+
+// valueName returns the name of a value, false if it has none
+func valueName(names []config.ValueName, value float64) (string, bool) {
+	for _, name := range names {
+		if name.Value == value {
+			return name.Name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+)
+
+// setControlMetadata exports the default rating, the display format and the
+// statistics settings of a control, labels are empty when the structure file
+// has none
+func setControlMetadata(structure *loxone.Structure, miniserver string, uuid string, control string) {
+	rating := ""
+	if value, ok := structure.Ratings[uuid]; ok {
+		rating = strconv.Itoa(value)
+	}
+
+	details := structure.Details[uuid]
+	format := details.Format
+	if format == "" {
+		// Meters have no value, their actual value is the one shown
+		format = details.ActualFormat
+	}
+
+	frequency := ""
+	outputs := make([]string, 0)
+	if statistic, ok := structure.Statistics[uuid]; ok {
+		frequency = strconv.Itoa(statistic.Frequency)
+		for _, output := range statistic.Outputs {
+			outputs = append(outputs, output.Name)
+		}
+	}
+
+	controlMetadata.WithLabelValues(miniserver, control, uuid, rating, format, frequency, strings.Join(outputs, ",")).Set(1)
+}
@@ -0,0 +1,19 @@
+package collector
+
+import "strings"
+
+// meterControlTypes are the controls with cumulative total states
+var meterControlTypes = map[string]bool{
+	"Meter":             true,
+	"EFM":               true,
+	"EnergyFlowMonitor": true,
+}
+
+// isMeterTotal tells whether a state is a total counting up, like the total
+// or totalNeg of a Meter. The totalLast* states hold the previous period and
+// aren't counters.
+func isMeterTotal(controlType string, state string) bool {
+	return meterControlTypes[controlType] &&
+		strings.HasPrefix(state, "total") &&
+		!strings.HasPrefix(state, "totalLast")
+}
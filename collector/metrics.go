@@ -0,0 +1,240 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// labelNames are the labels of every state
+var labelNames = []string{"miniserver", "control", "room", "type", "cat", "state"}
+
+// seriesLabelNames are the labels of every per state series, the ones of
+// --metrics.labels or all of labelNames
+var seriesLabelNames []string
+
+var (
+	valueHistogram *prometheus.HistogramVec
+	controlInfo    = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_control_info",
+			Help: "Metadata of every control, always 1",
+		},
+		[]string{"miniserver", "control", "room", "cat", "type", "uuid"},
+	)
+	controlMetadata = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_control_metadata_info",
+			Help: "Default rating, display format and statistics settings of every control from the structure file, always 1",
+		},
+		[]string{"miniserver", "control", "uuid", "default_rating", "format", "statistics_frequency", "statistics"},
+	)
+	connected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_connected",
+			Help: "Whether the exporter is connected to the Miniserver",
+		},
+		[]string{"miniserver"},
+	)
+	eventsReceived = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loxone_events_received_total",
+			Help: "Number of events received from the Miniserver",
+		},
+		[]string{"miniserver"},
+	)
+	eventsByType = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loxone_events_by_type_total",
+			Help: "Number of events received for states of known controls, by control type",
+		},
+		[]string{"miniserver", "type"},
+	)
+	unknownEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loxone_unknown_events_total",
+			Help: "Number of events received for unknown UUIDs",
+		},
+		[]string{"miniserver"},
+	)
+	lastEvent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_last_event_timestamp_seconds",
+			Help: "Unix timestamp of the last event received",
+		},
+		[]string{"miniserver"},
+	)
+	configControls = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_config_controls",
+			Help: "Number of controls in the structure file",
+		},
+		[]string{"miniserver"},
+	)
+	eventsDeduplicated = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loxone_events_deduplicated_total",
+			Help: "Number of events ignored because they repeated the value of the state",
+		},
+		[]string{"miniserver"},
+	)
+	bufferedEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loxone_startup_buffered_events_total",
+			Help: "Number of events received before the state map was built",
+		},
+		[]string{"miniserver"},
+	)
+)
+
+var defaultValueBuckets = []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// RegisterMetrics creates the per state metrics for the configured label set
+// and registers everything the config asks for
+func RegisterMetrics(cfg *config.Config) {
+	if cfg.ArrayChildLabels {
+		labelNames = append(labelNames, "element")
+	}
+	if floorsEnabled(cfg) {
+		labelNames = append(labelNames, "floor")
+	}
+	if cfg.SubControls {
+		labelNames = append(labelNames, "subcontrol")
+	}
+	if cfg.UUIDLabels {
+		labelNames = append(labelNames, "control_uuid", "room_uuid")
+	}
+	seriesLabelNames = labelNames
+	if len(cfg.Metrics.Labels) > 0 {
+		seriesLabelNames = make([]string, 0, len(cfg.Metrics.Labels))
+		for _, name := range labelNames {
+			if contains(cfg.Metrics.Labels, name) {
+				seriesLabelNames = append(seriesLabelNames, name)
+			}
+		}
+	}
+
+	prometheus.MustRegister(vectorChildren)
+	prometheus.MustRegister(bufferedEvents)
+	prometheus.MustRegister(connected)
+	prometheus.MustRegister(eventsReceived)
+	prometheus.MustRegister(eventsByType)
+	prometheus.MustRegister(unknownEvents)
+	prometheus.MustRegister(eventsDropped)
+	prometheus.MustRegister(lastEvent)
+	prometheus.MustRegister(configControls)
+	prometheus.MustRegister(up)
+	prometheus.MustRegister(reconnects)
+	prometheus.MustRegister(tokenRefreshes)
+	prometheus.MustRegister(tokenExpiry)
+	registerWeatherMetrics()
+	prometheus.MustRegister(miniserverInfo)
+	prometheus.MustRegister(userRights)
+	prometheus.MustRegister(apiRequestDuration)
+	prometheus.MustRegister(apiRequestErrors)
+	if valueHistogramsEnabled(cfg) {
+		valueHistogram = newValueHistogram(cfg)
+		prometheus.MustRegister(valueHistogram)
+	}
+	if cfg.EventHistograms {
+		newEventHistograms(cfg)
+		prometheus.MustRegister(eventInterval)
+		prometheus.MustRegister(eventProcessing)
+	}
+	if cfg.SystemStatsInterval > 0 {
+		prometheus.MustRegister(miniserverCPU)
+		prometheus.MustRegister(miniserverHeap)
+		prometheus.MustRegister(miniserverTasks)
+	}
+	if cfg.Clock.Interval > 0 {
+		prometheus.MustRegister(clockOffset)
+	}
+	if cfg.Dedup {
+		prometheus.MustRegister(eventsDeduplicated)
+	}
+	if rateLimitEnabled(cfg) {
+		prometheus.MustRegister(eventsRateLimited)
+	}
+	if cfg.ControlInfo {
+		prometheus.MustRegister(controlInfo)
+	}
+	if cfg.ControlMetadata {
+		prometheus.MustRegister(controlMetadata)
+	}
+	if cfg.SecurityMetrics {
+		prometheus.MustRegister(alarmArmed)
+		prometheus.MustRegister(alarmTriggered)
+		prometheus.MustRegister(alarmTriggeredTotal)
+		prometheus.MustRegister(alarmLevel)
+		prometheus.MustRegister(alarmLastTrigger)
+	}
+	if cfg.AccessMetrics {
+		prometheus.MustRegister(bellPresses)
+		prometheus.MustRegister(lastBell)
+		prometheus.MustRegister(accesses)
+		prometheus.MustRegister(lastAccess)
+	}
+}
+
+// prunableVectors are the registered per state vectors by metric name
+func prunableVectors(cfg *config.Config) map[string]vector {
+	vectors := map[string]vector{}
+	if valueHistogramsEnabled(cfg) {
+		vectors["loxone_value_histogram"] = valueHistogram
+	}
+	return vectors
+}
+
+// valueHistogramsEnabled tells whether loxone_value_histogram records the
+// values of all states or of the selected ones
+func valueHistogramsEnabled(cfg *config.Config) bool {
+	return cfg.ValueHistograms || len(cfg.ValueHistogramStates) > 0
+}
+
+func newValueHistogram(cfg *config.Config) *prometheus.HistogramVec {
+	opts := prometheus.HistogramOpts{
+		Name: "loxone_value_histogram",
+		Help: "Distribution of received values",
+	}
+
+	if cfg.NativeHistograms {
+		opts.NativeHistogramBucketFactor = 1.1
+		opts.NativeHistogramMaxBucketNumber = 160
+		opts.NativeHistogramMinResetDuration = time.Hour
+	} else if len(cfg.ValueHistogramBuckets) > 0 {
+		opts.Buckets = cfg.ValueHistogramBuckets
+	} else {
+		opts.Buckets = defaultValueBuckets
+	}
+
+	return prometheus.NewHistogramVec(opts, seriesLabelNames)
+}
+
+// seriesLabels returns the labels of a state kept in its series
+func seriesLabels(labels prometheus.Labels) prometheus.Labels {
+	if len(seriesLabelNames) == len(labelNames) {
+		return labels
+	}
+	result := make(prometheus.Labels, len(seriesLabelNames))
+	for _, name := range seriesLabelNames {
+		result[name] = labels[name]
+	}
+	return result
+}
+
+// SeriesLabels returns the labels of the series of a state with these labels,
+// RegisterMetrics must have been called
+func SeriesLabels(labels prometheus.Labels) prometheus.Labels {
+	return seriesLabels(labels)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// umlauts are transliterated to ASCII by normalize-labels rules with transliterate
+var umlauts = strings.NewReplacer(
+	"ä", "ae", "ö", "oe", "ü", "ue",
+	"Ä", "Ae", "Ö", "Oe", "Ü", "Ue",
+	"ß", "ss",
+)
+
+// normalizeLabel trims a label value, collapses its whitespace and replaces
+// invalid UTF-8, then transliterates and lowercases it if the rule says so
+func normalizeLabel(rule config.NormalizeConfig, value string) string {
+	value = strings.ToValidUTF8(value, "�")
+	value = strings.Join(strings.FieldsFunc(value, unicode.IsSpace), " ")
+	if rule.Transliterate {
+		value = umlauts.Replace(value)
+	}
+	if rule.Lowercase {
+		value = strings.ToLower(value)
+	}
+	return value
+}
+
+// normalizeLabels applies the rules to the labels in place, every rule to
+// the labels it lists
+func normalizeLabels(rules []config.NormalizeConfig, labels prometheus.Labels) {
+	for _, rule := range rules {
+		for _, name := range rule.Labels {
+			if value, ok := labels[name]; ok {
+				labels[name] = normalizeLabel(rule, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// persistedState is the part of a state that survives restarts
+type persistedState struct {
+	Changes    float64   `json:"changes"`
+	LastChange time.Time `json:"last_change"`
+}
+
+// RestoreChanges reads the change counters saved by SaveChanges, they are
+// taken over when the structure file of their Miniserver is mapped. A missing
+// file is not an error.
+func (c *ValuesCollector) RestoreChanges(file string) error {
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	restored := make(map[string]map[string]persistedState)
+	err = json.Unmarshal(content, &restored)
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.restored = restored
+	return nil
+}
+
+// SaveChanges writes the change counters of all states to the file
+func (c *ValuesCollector) SaveChanges(file string) error {
+	c.RLock()
+	saved := make(map[string]map[string]persistedState, len(c.miniservers)+len(c.restored))
+	// Miniservers that didn't connect yet keep their restored counters
+	for miniserver, states := range c.restored {
+		saved[miniserver] = states
+	}
+	for miniserver, m := range c.miniservers {
+		states := make(map[string]persistedState, len(m.states))
+		for uuid, state := range m.states {
+			state.Lock()
+			if state.changes > 0 {
+				states[uuid] = persistedState{Changes: state.changes, LastChange: state.lastChange}
+			}
+			state.Unlock()
+		}
+		saved[miniserver] = states
+	}
+	c.RUnlock()
+
+	content, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	// Write a temporary file first, a crash must not leave a truncated file behind
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// PersistChanges saves the change counters every interval until the context is done
+func (c *ValuesCollector) PersistChanges(ctx context.Context, file string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := c.SaveChanges(file)
+			if err != nil {
+				log.Errorf("Unable to save the change counters: %v", err)
+			}
+		}
+	}
+}
+
+// takeRestored returns the restored counters of a Miniserver, only once
+func (c *ValuesCollector) takeRestored(miniserver string) map[string]persistedState {
+	c.Lock()
+	defer c.Unlock()
+	states := c.restored[miniserver]
+	delete(c.restored, miniserver)
+	return states
+}
+
+// restore takes the counters of a persisted state
+func (e *eventMetric) restore(state persistedState) {
+	e.Lock()
+	defer e.Unlock()
+	e.changes, e.lastChange = state.Changes, state.LastChange
+}
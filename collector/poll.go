@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	"github.com/XciD/loxone-ws/events"
+)
+
+const defaultPollState = "value"
+
+// pollTarget is a control polled for the value of one of its states
+type pollTarget struct {
+	control  string
+	state    string
+	interval time.Duration
+}
+
+// pollTargets finds the controls of the poll config in the structure file
+func (s *session) pollTargets(structure *loxone.Structure, polls []config.PollConfig) []pollTarget {
+	targets := make([]pollTarget, 0, len(polls))
+	for _, poll := range polls {
+		if poll.Interval <= 0 {
+			s.log.Warnf("Unable to poll %s without an interval", poll.Control)
+			continue
+		}
+		stateName := poll.State
+		if stateName == "" {
+			stateName = defaultPollState
+		}
+
+		found := false
+		for uuid, control := range structure.Controls {
+			if uuid != poll.Control && control.Name != poll.Control {
+				continue
+			}
+			found = true
+			stateUUID, ok := control.States[stateName].(string)
+			if !ok {
+				s.log.Warnf("Unable to poll %s, it has no state %s", poll.Control, stateName)
+				continue
+			}
+			targets = append(targets, pollTarget{control: uuid, state: stateUUID, interval: poll.Interval})
+		}
+		if !found {
+			s.log.Warnf("Unable to poll %s, there is no such control", poll.Control)
+		}
+	}
+	return targets
+}
+
+// poll reads the value of the control every interval and sends it as an
+// event of the state, until the context is done
+func (s *session) poll(ctx context.Context, lox *loxone.Client, target pollTarget, polled chan<- *events.Event) {
+	ticker := time.NewTicker(target.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var value float64
+		err := s.request(endpointControl, func() (err error) {
+			value, err = lox.ControlValue(target.control)
+			return err
+		})
+		if err != nil {
+			s.log.Warnf("Unable to poll %s: %v", target.control, err)
+			continue
+		}
+		select {
+		case polled <- &events.Event{UUID: target.state, Value: value}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var eventsDropped = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "loxone_events_dropped_total",
+		Help: "Number of events dropped because the event queue was full",
+	},
+	[]string{"miniserver"},
+)
+
+type queuedEvent struct {
+	state *eventMetric
+	value float64
+	// release applies the value held back by the rate limit instead
+	release bool
+}
+
+// eventPool updates the states in worker goroutines. The events of a state
+// always go to the same worker, so they are processed in order.
+type eventPool struct {
+	miniserver string
+	queues     []chan queuedEvent
+	wg         sync.WaitGroup
+}
+
+// newEventPool starts the workers, each with a queue of the given size
+func newEventPool(miniserver string, workers int, size int, process func(*eventMetric, float64)) *eventPool {
+	p := &eventPool{miniserver: miniserver, queues: make([]chan queuedEvent, workers)}
+	for i := range p.queues {
+		queue := make(chan queuedEvent, size)
+		p.queues[i] = queue
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for event := range queue {
+				if event.release {
+					event.state.release()
+					continue
+				}
+				process(event.state, event.value)
+			}
+		}()
+	}
+	return p
+}
+
+// submit queues the event of a state, it is dropped if the queue is full
+func (p *eventPool) submit(uuid string, state *eventMetric, value float64) {
+	select {
+	case p.queue(uuid) <- queuedEvent{state: state, value: value}:
+	default:
+		eventsDropped.WithLabelValues(p.miniserver).Inc()
+	}
+}
+
+// submitRelease queues the release of the value held back by the rate limit
+// of a state. Unlike events it waits for room in the queue, the state would
+// hold back every following value otherwise.
+func (p *eventPool) submitRelease(uuid string, state *eventMetric) {
+	p.queue(uuid) <- queuedEvent{state: state, release: true}
+}
+
+// queue returns the queue of the worker of a state
+func (p *eventPool) queue(uuid string) chan queuedEvent {
+	hash := fnv.New32a()
+	hash.Write([]byte(uuid))
+	return p.queues[hash.Sum32()%uint32(len(p.queues))]
+}
+
+// stop processes the queued events and stops the workers
+func (p *eventPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
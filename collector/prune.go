@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+var vectorChildren = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "loxone_metric_vector_children",
+		Help: "Number of series of each per state metric",
+	},
+	[]string{"metric"},
+)
+
+// vector is implemented by CounterVec, GaugeVec and HistogramVec
+type vector interface {
+	prometheus.Collector
+	Delete(prometheus.Labels) bool
+}
+
+// labelsKey identifies a label set of seriesLabelNames
+func labelsKey(labels prometheus.Labels) string {
+	values := make([]string, 0, len(seriesLabelNames))
+	for _, name := range seriesLabelNames {
+		values = append(values, labels[name])
+	}
+	return strings.Join(values, "\x00")
+}
+
+// vectorLabels returns the label sets of all series of a vector
+func vectorLabels(vec vector) []prometheus.Labels {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		vec.Collect(ch)
+		close(ch)
+	}()
+
+	result := make([]prometheus.Labels, 0)
+	for metric := range ch {
+		pb := &dto.Metric{}
+		if err := metric.Write(pb); err != nil {
+			continue
+		}
+		labels := prometheus.Labels{}
+		for _, pair := range pb.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		result = append(result, labels)
+	}
+	return result
+}
+
+// pruneVectors deletes the series of states that are no longer mapped
+// and reports how many series each vector holds
+func pruneVectors(states []*eventMetric, vectors map[string]vector) {
+	active := make(map[string]bool, len(states))
+	for _, state := range states {
+		active[labelsKey(*state.labels)] = true
+	}
+	vectorChildren.WithLabelValues("loxone_values").Set(float64(len(states)))
+
+	for name, vec := range vectors {
+		children := 0
+		for _, labels := range vectorLabels(vec) {
+			if active[labelsKey(labels)] {
+				children++
+				continue
+			}
+			log.Debugf("Pruning %s%v", name, labels)
+			vec.Delete(labels)
+		}
+		vectorChildren.WithLabelValues(name).Set(float64(children))
+	}
+}
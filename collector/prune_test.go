@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPruneVectors(t *testing.T) {
+	previous := seriesLabelNames
+	seriesLabelNames = []string{"miniserver", "control", "state"}
+	defer func() { seriesLabelNames = previous }()
+
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_value_histogram"}, seriesLabelNames)
+	kept := prometheus.Labels{"miniserver": "home", "control": "Ceiling", "state": "position"}
+	renamed := prometheus.Labels{"miniserver": "home", "control": "Old name", "state": "position"}
+	histogram.With(kept).Observe(1)
+	histogram.With(renamed).Observe(1)
+
+	states := []*eventMetric{
+		newEventMetric(&prometheus.Labels{"miniserver": "home", "control": "Ceiling", "state": "position", "room": "Kitchen"}, nil, 0),
+		newEventMetric(&prometheus.Labels{"miniserver": "home", "control": "Spots", "state": "active"}, nil, 0),
+	}
+	pruneVectors(states, map[string]vector{"test_value_histogram": histogram})
+
+	series := vectorLabels(histogram)
+	if len(series) != 1 || labelsKey(series[0]) != labelsKey(kept) {
+		t.Errorf("series after pruning are %v, want only %v", series, kept)
+	}
+	if children := metricValue(t, vectorChildren.WithLabelValues("test_value_histogram")); children != 1 {
+		t.Errorf("loxone_metric_vector_children of the histogram is %v, want 1", children)
+	}
+	if children := metricValue(t, vectorChildren.WithLabelValues("loxone_values")); children != 2 {
+		t.Errorf("loxone_metric_vector_children of loxone_values is %v, want 2", children)
+	}
+}
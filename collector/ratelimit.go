@@ -0,0 +1,93 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var eventsRateLimited = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "loxone_events_rate_limited_total",
+		Help: "Number of events held back by the rate limit of their state, the last one of a burst is applied later",
+	},
+	[]string{"miniserver"},
+)
+
+// rateLimitEnabled tells whether any state can be rate limited
+func rateLimitEnabled(cfg *config.Config) bool {
+	return cfg.RateLimit > 0 || len(cfg.RateLimitOverrides) > 0
+}
+
+// rateLimit returns the updates per second of a control, an override of the
+// control wins over one of its type
+func rateLimit(rate float64, overrides []config.RateLimitConfig, labels prometheus.Labels) float64 {
+	byType := -1
+	for i, override := range overrides {
+		if override.Control != "" && override.Control == labels["control"] {
+			return override.Rate
+		}
+		if byType < 0 && override.Control == "" && override.Type == labels["type"] {
+			byType = i
+		}
+	}
+	if byType >= 0 {
+		return overrides[byType].Rate
+	}
+	return rate
+}
+
+// rateLimiter lets one update of a state through per interval, the last
+// value held back meanwhile is applied once the interval is over
+type rateLimiter struct {
+	sync.Mutex
+	interval time.Duration
+	// next is the earliest time of the next update
+	next    time.Time
+	pending bool
+	value   float64
+	// release asks the goroutine updating the state to take the value held
+	// back, it's called by a timer once the interval is over
+	release func()
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// allow tells whether the value can be applied now, otherwise it's held back
+// until release is called and take returns the last value held back
+func (l *rateLimiter) allow(value float64) bool {
+	l.Lock()
+	defer l.Unlock()
+	now := time.Now()
+	if l.release == nil || (!l.pending && !now.Before(l.next)) {
+		l.next = now.Add(l.interval)
+		return true
+	}
+
+	l.value = value
+	if !l.pending {
+		l.pending = true
+		time.AfterFunc(l.next.Sub(now), l.release)
+	}
+	return false
+}
+
+// take returns the last value held back, if any, and starts the next interval
+func (l *rateLimiter) take() (float64, bool) {
+	l.Lock()
+	defer l.Unlock()
+	if !l.pending {
+		return 0, false
+	}
+	l.pending = false
+	l.next = time.Now().Add(l.interval)
+	return l.value, true
+}
@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	"github.com/XciD/loxone-ws/events"
+)
+
+func TestRateLimitReleasesInReadLoop(t *testing.T) {
+	for _, workers := range []int{0, 2} {
+		cfg := &config.Config{RateLimit: 20, EventWorkers: workers, EventQueueSize: 10}
+		parsed, err := loxone.ParseStructure([]byte(testStructure))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mapper, err := NewStateMapper(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := newSession(cfg, config.MiniserverConfig{Name: "home"}, mapper, NewValuesCollector(cfg), newConnectionState("home", 0))
+		if workers > 0 {
+			s.pool = newEventPool("home", workers, cfg.EventQueueSize, s.process)
+		}
+		s.mapStructure(parsed, prunableVectors(cfg))
+
+		for _, value := range []float64{1, 2, 3} {
+			s.handleEvent(&events.Event{UUID: "s2", Value: value})
+		}
+		select {
+		case held := <-s.released:
+			s.release(held)
+		case <-time.After(time.Second):
+			t.Fatalf("%d workers: the held back value was not released", workers)
+		}
+		if s.pool != nil {
+			s.pool.stop()
+		}
+		close(s.done)
+
+		state := s.states["s2"]
+		state.Lock()
+		value := state.value
+		state.Unlock()
+		if value != 3 {
+			t.Errorf("%d workers: value after the release is %v, want the last one held back", workers, value)
+		}
+	}
+}
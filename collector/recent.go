@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RecentEvent is an event applied to a state, with the labels of the state
+type RecentEvent struct {
+	Miniserver string    `json:"miniserver"`
+	Control    string    `json:"control"`
+	Room       string    `json:"room"`
+	Cat        string    `json:"cat"`
+	Type       string    `json:"type"`
+	State      string    `json:"state"`
+	Value      float64   `json:"value"`
+	Time       time.Time `json:"time"`
+}
+
+// eventRing keeps the last events in a ring buffer
+type eventRing struct {
+	sync.Mutex
+	events []RecentEvent
+	// next is where the next event goes, the oldest one once the ring is full
+	next int
+	full bool
+}
+
+func newEventRing(size int) *eventRing {
+	if size <= 0 {
+		return nil
+	}
+	return &eventRing{events: make([]RecentEvent, size)}
+}
+
+func (r *eventRing) add(labels prometheus.Labels, value float64, at time.Time) {
+	r.Lock()
+	defer r.Unlock()
+	r.events[r.next] = RecentEvent{
+		Miniserver: labels["miniserver"],
+		Control:    labels["control"],
+		Room:       labels["room"],
+		Cat:        labels["cat"],
+		Type:       labels["type"],
+		State:      labels["state"],
+		Value:      value,
+		Time:       at,
+	}
+	r.next++
+	if r.next == len(r.events) {
+		r.next, r.full = 0, true
+	}
+}
+
+// since returns the events after a time, of a control if set, the oldest first
+func (r *eventRing) since(since time.Time, control string) []RecentEvent {
+	r.Lock()
+	defer r.Unlock()
+	ordered := r.events[:r.next]
+	if r.full {
+		ordered = append(append([]RecentEvent{}, r.events[r.next:]...), r.events[:r.next]...)
+	}
+	result := make([]RecentEvent, 0)
+	for _, event := range ordered {
+		if event.Time.After(since) && (control == "" || event.Control == control) {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+// RecentEvents returns the events kept since a time, of a control if set,
+// the oldest first. Nothing is kept without --event-buffer.
+func (m *StateMapper) RecentEvents(since time.Time, control string) []RecentEvent {
+	if m.recent == nil {
+		return []RecentEvent{}
+	}
+	return m.recent.since(since, control)
+}
@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	relabelReplace   = "replace"
+	relabelKeep      = "keep"
+	relabelDrop      = "drop"
+	relabelLabelDrop = "labeldrop"
+)
+
+// relabelRule works like a Prometheus relabel_config, the label set is fixed
+// though: target_label must be an existing label and labeldrop empties labels
+type relabelRule struct {
+	sourceLabels []string
+	separator    string
+	regex        *regexp.Regexp
+	targetLabel  string
+	replacement  string
+	action       string
+}
+
+func compileRelabelRules(configs []config.RelabelConfig) ([]*relabelRule, error) {
+	known := make(map[string]bool)
+	for _, name := range labelNames {
+		known[name] = true
+	}
+
+	rules := make([]*relabelRule, 0, len(configs))
+	for i, c := range configs {
+		rule := &relabelRule{
+			sourceLabels: c.SourceLabels,
+			separator:    c.Separator,
+			targetLabel:  c.TargetLabel,
+			replacement:  c.Replacement,
+			action:       strings.ToLower(c.Action),
+		}
+		if rule.separator == "" {
+			rule.separator = ";"
+		}
+		if rule.replacement == "" {
+			rule.replacement = "$1"
+		}
+		if rule.action == "" {
+			rule.action = relabelReplace
+		}
+		expression := c.Regex
+		if expression == "" {
+			expression = "(.*)"
+		}
+
+		regex, err := regexp.Compile("^(?:" + expression + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("relabel rule %d: invalid regex: %v", i+1, err)
+		}
+		rule.regex = regex
+
+		switch rule.action {
+		case relabelReplace:
+			if !known[rule.targetLabel] {
+				return nil, fmt.Errorf("relabel rule %d: unknown target label %q", i+1, rule.targetLabel)
+			}
+		case relabelKeep, relabelDrop, relabelLabelDrop:
+		default:
+			return nil, fmt.Errorf("relabel rule %d: unknown action %q", i+1, rule.action)
+		}
+		for _, name := range rule.sourceLabels {
+			if !known[name] {
+				return nil, fmt.Errorf("relabel rule %d: unknown source label %q", i+1, name)
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// relabel applies the rules to the labels in place,
+// it returns false if the series is dropped
+func relabel(rules []*relabelRule, labels prometheus.Labels) bool {
+	for _, rule := range rules {
+		values := make([]string, 0, len(rule.sourceLabels))
+		for _, name := range rule.sourceLabels {
+			values = append(values, labels[name])
+		}
+		value := strings.Join(values, rule.separator)
+
+		switch rule.action {
+		case relabelReplace:
+			match := rule.regex.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			labels[rule.targetLabel] = string(rule.regex.ExpandString(nil, rule.replacement, value, match))
+		case relabelKeep:
+			if !rule.regex.MatchString(value) {
+				return false
+			}
+		case relabelDrop:
+			if rule.regex.MatchString(value) {
+				return false
+			}
+		case relabelLabelDrop:
+			for name := range labels {
+				if name != "miniserver" && rule.regex.MatchString(name) {
+					labels[name] = ""
+				}
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	"github.com/XciD/loxone-ws/events"
+	log "github.com/sirupsen/logrus"
+)
+
+// readRecordedStructure reads the structure file a recording starts with
+func readRecordedStructure(reader *loxone.RecordingReader) (*loxone.RecordEntry, *loxone.Structure, error) {
+	first, err := reader.Next()
+	if err != nil {
+		return nil, nil, err
+	}
+	if first.Structure == nil {
+		return nil, nil, errors.New("the recording doesn't start with a structure file")
+	}
+	structure, err := loxone.ParseStructure(first.Structure)
+	if err != nil {
+		return nil, nil, err
+	}
+	return first, structure, nil
+}
+
+// RecordedStructure returns the structure file a recording starts with and its Miniserver
+func RecordedStructure(file string) (*loxone.Structure, string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	first, structure, err := readRecordedStructure(loxone.NewRecordingReader(f))
+	if err != nil {
+		return nil, "", err
+	}
+	return structure, first.Miniserver, nil
+}
+
+// ReplayMiniserver feeds a recording into the collector instead of a live
+// Miniserver, with the delays between the events divided by --replay-speed.
+// The states stay exported once the recording is over, until the context is done.
+func ReplayMiniserver(ctx context.Context, cfg *config.Config, file string, mapper *StateMapper, values *ValuesCollector) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := loxone.NewRecordingReader(f)
+	first, structure, err := readRecordedStructure(reader)
+	if err != nil {
+		return err
+	}
+
+	miniserver := config.MiniserverConfig{Name: first.Miniserver}
+	upState := newConnectionState(miniserver.Name, 0)
+	s := newSession(cfg, miniserver, mapper, values, upState)
+	defer close(s.done)
+	if cfg.EventWorkers > 0 {
+		s.pool = newEventPool(miniserver.Name, cfg.EventWorkers, cfg.EventQueueSize, s.process)
+		defer s.pool.stop()
+	}
+	s.mapStructure(structure, prunableVectors(cfg))
+	upState.set(true)
+	s.log.Infof("Replaying %s", file)
+
+	last := first.Time
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if wait := entry.Time.Sub(last); wait > 0 && cfg.ReplaySpeed > 0 {
+			if !s.replayWait(ctx, time.Duration(float64(wait)/cfg.ReplaySpeed)) {
+				return nil
+			}
+		}
+		last = entry.Time
+
+		if entry.Structure != nil {
+			structure, err := loxone.ParseStructure(entry.Structure)
+			if err != nil {
+				return err
+			}
+			s.mapStructure(structure, prunableVectors(cfg))
+			continue
+		}
+		event := &events.Event{UUID: entry.UUID, Value: entry.Value}
+		if entry.Text != nil {
+			event.Text = &events.Text{Value: *entry.Text}
+		}
+		s.handleEvent(event)
+	}
+
+	log.Info("Replay finished")
+	// Values held back by the rate limit are still applied
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case held := <-s.released:
+			s.release(held)
+		}
+	}
+}
+
+// replayWait waits for the next event of a recording, applying the values
+// held back by the rate limit meanwhile, it returns false once ctx is done
+func (s *session) replayWait(ctx context.Context, wait time.Duration) bool {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case held := <-s.released:
+			s.release(held)
+		case <-timer.C:
+			return true
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var userRights = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "loxone_user_rights_info",
+		Help: "User the exporter is logged in as, with its rights bitmask, always 1",
+	},
+	[]string{"miniserver", "user", "admin", "rights"},
+)
+
+// checkUserRights exports the rights of the user the exporter is logged in
+// as and warns when they are more than reading needs
+func (s *session) checkUserRights(structure *loxone.Structure) {
+	user, ok := structure.CurrentUser()
+	if !ok {
+		s.log.Debug("The structure file doesn't tell the rights of the user")
+		return
+	}
+	rights := fmt.Sprintf("0x%x", user.Rights)
+	s.log.Infof("Logged in as %s, rights %s, admin %t", user.Name, rights, user.Admin)
+	if user.Admin {
+		s.log.Warnf("%s is an administrator, the exporter only reads the Miniserver, a user without admin rights is enough", user.Name)
+	}
+
+	userRights.DeletePartialMatch(prometheus.Labels{"miniserver": s.miniserver.Name})
+	userRights.WithLabelValues(s.miniserver.Name, user.Name, strconv.FormatBool(user.Admin), rights).Set(1)
+}
@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	"github.com/XciD/loxone-ws/events"
+)
+
+// securedStatePrefix starts the names of the states read from secured details
+const securedStatePrefix = "securedDetails."
+
+// securedStates reads the secured details of the controls having them with
+// the visualization password and adds their numbers and booleans as states
+// of the control, e.g. securedDetails.videoInfo.alertImage. It returns events
+// with their values, to handle once the structure file is mapped.
+func (s *session) securedStates(lox *loxone.Client, structure *loxone.Structure) []*events.Event {
+	if s.miniserver.VisuPassword == "" {
+		return nil
+	}
+
+	result := make([]*events.Event, 0)
+	for uuid, details := range structure.Details {
+		control, ok := structure.Controls[uuid]
+		if !details.SecuredDetails || !ok {
+			continue
+		}
+		var secured map[string]interface{}
+		err := s.request(endpointSecured, func() (err error) {
+			secured, err = lox.SecuredDetails(uuid, s.miniserver.User, s.miniserver.VisuPassword)
+			return err
+		})
+		if err != nil {
+			s.log.Warnf("Unable to read the secured details of %s: %v", control.Name, err)
+			continue
+		}
+		if control.States == nil {
+			control.States = make(map[string]interface{})
+		}
+		for name, value := range numericLeaves(secured) {
+			stateUUID := uuid + "/" + securedStatePrefix + name
+			control.States[securedStatePrefix+name] = stateUUID
+			result = append(result, &events.Event{UUID: stateUUID, Value: value})
+		}
+	}
+	return result
+}
+
+// numericLeaves returns the numbers and booleans of a JSON object by their dotted path
+func numericLeaves(object map[string]interface{}) map[string]float64 {
+	result := make(map[string]float64)
+	var walk func(path []string, value interface{})
+	walk = func(path []string, value interface{}) {
+		switch value := value.(type) {
+		case float64:
+			result[strings.Join(path, ".")] = value
+		case bool:
+			result[strings.Join(path, ".")] = boolValue(value)
+		case map[string]interface{}:
+			keys := make([]string, 0, len(value))
+			for key := range value {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				walk(append(path[:len(path):len(path)], key), value[key])
+			}
+		}
+	}
+	walk(nil, object)
+	return result
+}
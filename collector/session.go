@@ -0,0 +1,521 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	loxonews "github.com/XciD/loxone-ws"
+	"github.com/XciD/loxone-ws/events"
+	log "github.com/sirupsen/logrus"
+)
+
+// session is a single connection to a Miniserver, from login until
+// the connection is lost
+type session struct {
+	cfg        *config.Config
+	miniserver config.MiniserverConfig
+	mapper     *StateMapper
+	values     *ValuesCollector
+	upState    *connectionState
+	states     map[string]*eventMetric
+	structure  *loxone.Structure
+	recorder   *loxone.RecordingWriter
+	// pool updates the states, without it they are updated in the read loop
+	pool *eventPool
+	// released are the states whose value held back by the rate limit is due,
+	// they are handed to the read loop until done is closed
+	released  chan heldState
+	done      chan struct{}
+	log       *log.Entry
+	connected bool
+}
+
+// heldState is a state with a value held back by the rate limit
+type heldState struct {
+	uuid  string
+	state *eventMetric
+}
+
+func newSession(cfg *config.Config, miniserver config.MiniserverConfig, mapper *StateMapper, values *ValuesCollector, upState *connectionState) *session {
+	return &session{
+		cfg:        cfg,
+		miniserver: miniserver,
+		mapper:     mapper,
+		values:     values,
+		upState:    upState,
+		released:   make(chan heldState),
+		done:       make(chan struct{}),
+		log:        log.WithField("miniserver", miniserver.Name),
+	}
+}
+
+// RunMiniserver keeps a session to the Miniserver open until the context is done,
+// reconnecting with exponential backoff
+func RunMiniserver(ctx context.Context, cfg *config.Config, miniserver config.MiniserverConfig, mapper *StateMapper, values *ValuesCollector) {
+	upState := newConnectionState(miniserver.Name, cfg.UpDownGrace)
+	retry := loxone.NewBackoff(cfg.ReconnectBackoff, cfg.ReconnectMaxBackoff)
+
+	var recorder *loxone.RecordingWriter
+	if cfg.Record != "" {
+		file, err := os.OpenFile(miniserverFile(cfg, cfg.Record, miniserver.Name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			log.WithField("miniserver", miniserver.Name).Errorf("Unable to record: %v", err)
+		} else {
+			defer file.Close()
+			recorder = loxone.NewRecordingWriter(file)
+		}
+	}
+
+	// authFailed keeps hooks from being told about every retry with wrong credentials
+	authFailed := false
+	for {
+		session := newSession(cfg, miniserver, mapper, values, upState)
+		session.recorder = recorder
+		err := session.run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		upState.set(false)
+		values.deactivate(miniserver.Name)
+		if session.connected {
+			authFailed = false
+			notifyConnection(miniserver.Name, EventDisconnect, err)
+		} else if loxone.IsAuthError(err) && !authFailed {
+			authFailed = true
+			notifyConnection(miniserver.Name, EventAuthFailure, err)
+		}
+
+		if session.connected {
+			retry.Reset()
+		}
+		wait := retry.Next()
+		if session.connected {
+			session.log.Warnf("Connection to Miniserver lost (%v), reconnecting in %s", err, wait)
+		} else {
+			session.log.Warnf("Unable to connect to Miniserver (%v), retrying in %s", err, wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		reconnects.WithLabelValues(miniserver.Name).Inc()
+	}
+}
+
+// ResolveAddress returns the host of the Miniserver, from the Cloud DNS if it
+// has a serial number, and its address and whether it's secure as of
+// loxone.MiniserverAddress. It refuses plaintext addresses unless --allow-plaintext is set.
+func ResolveAddress(ctx context.Context, cfg *config.Config, miniserver config.MiniserverConfig, logger *log.Entry) (string, string, bool, error) {
+	host := miniserver.Host
+	if miniserver.Serial != "" {
+		resolved, err := loxone.ResolveCloudDNS(ctx, miniserver.Serial)
+		if err != nil {
+			return "", "", false, err
+		}
+		logger.Infof("Cloud DNS resolved %s to %s", miniserver.Serial, resolved)
+		host = resolved
+	}
+
+	address, secure := loxone.MiniserverAddress(host)
+	if !secure && !cfg.AllowPlaintext {
+		return "", "", false, fmt.Errorf("refusing to log in to %s without TLS, use wss:// or --allow-plaintext", host)
+	}
+	return host, address, secure, nil
+}
+
+// connect resolves the address of the Miniserver and logs in, it returns
+// the client and the host it connected to
+func connect(ctx context.Context, cfg *config.Config, miniserver config.MiniserverConfig, logger *log.Entry) (*loxone.Client, string, error) {
+	host, address, secure, err := ResolveAddress(ctx, cfg, miniserver, logger)
+	if err != nil {
+		return nil, "", err
+	}
+
+	lox, err := loxone.Connect(address, secure, miniserver.User, miniserver.Password, cfg.TokenPermission)
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.KeepaliveTimeout > 0 {
+		lox.Timeout = cfg.KeepaliveTimeout
+	}
+	if cfg.EncryptCommands {
+		err = lox.EncryptCommands()
+		if err != nil {
+			lox.Close()
+			return nil, "", err
+		}
+		logger.Info("Key exchange OK, commands are encrypted")
+	}
+	return lox, host, nil
+}
+
+// FetchStructure logs in to the Miniserver and downloads its structure file
+func FetchStructure(ctx context.Context, cfg *config.Config, miniserver config.MiniserverConfig) (*loxone.Structure, error) {
+	lox, _, err := connect(ctx, cfg, miniserver, log.WithField("miniserver", miniserver.Name))
+	if err != nil {
+		return nil, err
+	}
+	defer lox.Close()
+	return loxone.GetStructure(lox)
+}
+
+// run connects, maps the structure file and processes events until the
+// connection is lost or the context is done
+func (s *session) run(ctx context.Context) error {
+	cfg := s.cfg
+	name := s.miniserver.Name
+	defer close(s.done)
+
+	lox, host, err := connect(ctx, cfg, s.miniserver, s.log)
+	if err != nil {
+		return err
+	}
+	// Close logs out, so a lost session doesn't stay open on the Miniserver
+	// next to the one of the reconnect
+	defer func() {
+		removeCommandTarget(name)
+		lox.Close()
+	}()
+
+	// Get config
+	var loxoneConfig *loxone.Structure
+	err = s.request(endpointStructure, func() (err error) {
+		loxoneConfig, err = loxone.GetStructure(lox)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.log.Info("Get Config OK")
+	s.checkUserRights(loxoneConfig)
+	secured := s.securedStates(lox, loxoneConfig)
+
+	// Events keep coming while we build the map, hold them back until it's
+	// ready. The buffer starts before the registration, loxone-ws delivers
+	// answers only while somebody reads its events, so the info probe below
+	// would otherwise wait for the first events to be read.
+	startupEvents := loxone.NewEventBuffer(lox.Events)
+
+	// Register events
+	err = lox.RegisterEvents()
+	if err != nil {
+		startupEvents.Stop()
+		return err
+	}
+	s.log.Info("RegisterEvents OK")
+
+	s.connected = true
+	s.upState.set(true)
+	notifyConnection(name, EventConnect, nil)
+
+	var info *loxonews.SimpleValue
+	if err := s.request(endpointInfo, func() (err error) {
+		info, err = lox.Probe()
+		return err
+	}); err == nil {
+		updateMiniserverInfo(name, info, host)
+	} else {
+		s.log.Warnf("Unable to read Miniserver info: %v", err)
+	}
+
+	if cfg.EventWorkers > 0 {
+		s.pool = newEventPool(name, cfg.EventWorkers, cfg.EventQueueSize, s.process)
+		defer s.pool.stop()
+	}
+
+	// Build Control Map by states
+	vectors := prunableVectors(cfg)
+	s.mapStructure(loxoneConfig, vectors)
+	setCommandTarget(name, lox, loxoneConfig)
+
+	for _, event := range secured {
+		s.handleEvent(event)
+	}
+
+	buffered := startupEvents.Stop()
+	s.log.Infof("Replaying %d events received during startup", len(buffered))
+	for _, event := range buffered {
+		bufferedEvents.WithLabelValues(name).Inc()
+		s.handleEvent(event)
+	}
+
+	keepalive := cfg.KeepaliveInterval
+	if keepalive <= 0 {
+		keepalive = loxone.ProbeInterval
+	}
+	// The keepalive and the token refresh end the session when they fail
+	lost := make(chan error, 2)
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		lost <- lox.Watch(watchCtx, keepalive, s.upState.alive)
+	}()
+	go func() {
+		if err := s.keepToken(watchCtx, lox); err != nil {
+			lost <- err
+		}
+	}()
+	if cfg.SystemStatsInterval > 0 {
+		go s.pollSystemStats(watchCtx, lox, cfg.SystemStatsInterval)
+	}
+	if cfg.Clock.Interval > 0 {
+		go s.pollClock(watchCtx, lox, cfg.Clock.Interval)
+	}
+	// Polled values are handled like events, by this goroutine
+	polled := make(chan *events.Event)
+	for _, target := range s.pollTargets(loxoneConfig, cfg.Poll) {
+		go s.poll(watchCtx, lox, target, polled)
+	}
+
+	var prune <-chan time.Time
+	if cfg.PruneInterval > 0 {
+		ticker := time.NewTicker(cfg.PruneInterval)
+		defer ticker.Stop()
+		prune = ticker.C
+	}
+
+	var structureCheck <-chan time.Time
+	if cfg.StructureCheckInterval > 0 {
+		ticker := time.NewTicker(cfg.StructureCheckInterval)
+		defer ticker.Stop()
+		structureCheck = ticker.C
+	}
+	version := loxoneConfig.LastModified
+	mapperChanged := s.mapper.changes()
+
+	s.log.Info("Start reading events")
+	for {
+		select {
+		case <-ctx.Done():
+			s.shutdown(lox)
+			return ctx.Err()
+		case err := <-lost:
+			return err
+		case <-lox.Done():
+			return errors.New("connection closed by the Miniserver")
+		case event := <-lox.Events:
+			s.handleEvent(event)
+		case event := <-polled:
+			s.handleEvent(event)
+		case held := <-s.released:
+			s.release(held)
+		case <-prune:
+			pruneVectors(s.values.allStates(), vectors)
+		case <-mapperChanged:
+			s.log.Info("Config reloaded, mapping the structure file again")
+			mapperChanged = s.mapper.changes()
+			s.mapStructure(s.structure, vectors)
+		case <-structureCheck:
+			current, err := s.structureVersion(lox)
+			if err != nil {
+				s.log.Warnf("Unable to check the structure file version: %v", err)
+				continue
+			}
+			if current == version {
+				continue
+			}
+			s.log.Infof("Structure file changed (%s), reloading", current)
+			err = s.reloadStructure(lox, vectors)
+			if err != nil {
+				return err
+			}
+			version = current
+		}
+	}
+}
+
+// mapStructure maps the structure file to states and exports them, the
+// values of states already known are kept
+func (s *session) mapStructure(loxoneConfig *loxone.Structure, vectors map[string]vector) {
+	name := s.miniserver.Name
+	configControls.WithLabelValues(name).Set(float64(len(loxoneConfig.Controls)))
+	updateStructureInfo(name, loxoneConfig.MsInfo)
+
+	s.structure = loxoneConfig
+	s.record(&loxone.RecordEntry{Miniserver: name, Structure: loxoneConfig.Raw})
+	globalStates, report := s.mapper.build(loxoneConfig, name)
+	s.log.Infof("Mapped %d series from %d controls", report.Series, report.Controls)
+	previousStates := s.values.previousStates(name)
+	restored := s.values.takeRestored(name)
+	for uuid, state := range globalStates {
+		if previous, ok := previousStates[uuid]; ok {
+			state.carryOver(previous)
+		} else if persisted, ok := restored[uuid]; ok {
+			state.restore(persisted)
+		}
+		if state.limiter != nil {
+			state.limiter.release = s.releaseLater(uuid, state)
+		}
+	}
+	s.states = globalStates
+	unknownLog.forget(name, globalStates)
+
+	if s.cfg.ReportFile != "" {
+		err := report.write(miniserverFile(s.cfg, s.cfg.ReportFile, name))
+		if err != nil {
+			s.log.Errorf("Unable to write report: %v", err)
+		}
+	}
+
+	s.values.setStates(name, globalStates, report.controls)
+	pruneVectors(s.values.allStates(), vectors)
+}
+
+// structureVersion reads the version of the structure file, events received
+// meanwhile are buffered and handled afterwards
+func (s *session) structureVersion(lox *loxone.Client) (string, error) {
+	versionEvents := loxone.NewEventBuffer(lox.Events)
+	var current string
+	err := s.request(endpointStructureVersion, func() (err error) {
+		current, err = loxone.StructureVersion(lox)
+		return err
+	})
+	for _, event := range versionEvents.Stop() {
+		s.handleEvent(event)
+	}
+	return current, err
+}
+
+// reloadStructure downloads the structure file again and remaps it, events
+// are held back meanwhile so loxone-ws can deliver the file
+func (s *session) reloadStructure(lox *loxone.Client, vectors map[string]vector) error {
+	reloadEvents := loxone.NewEventBuffer(lox.Events)
+	var loxoneConfig *loxone.Structure
+	err := s.request(endpointStructure, func() (err error) {
+		loxoneConfig, err = loxone.GetStructure(lox)
+		return err
+	})
+	var secured []*events.Event
+	if err == nil {
+		secured = s.securedStates(lox, loxoneConfig)
+	}
+	buffered := reloadEvents.Stop()
+	if err == nil {
+		s.mapStructure(loxoneConfig, vectors)
+		setCommandTarget(s.miniserver.Name, lox, loxoneConfig)
+	}
+	for _, event := range secured {
+		s.handleEvent(event)
+	}
+	for _, event := range buffered {
+		s.handleEvent(event)
+	}
+	return err
+}
+
+// shutdown handles the events already received, the deferred cleanup of
+// run closes the connection
+func (s *session) shutdown(lox *loxone.Client) {
+	for {
+		select {
+		case event := <-lox.Events:
+			s.handleEvent(event)
+		default:
+			s.log.Info("Closing connection")
+			return
+		}
+	}
+}
+
+func (s *session) handleEvent(event *events.Event) {
+	// Weather tables and daytimer schedules aren't recorded, replays have neither
+	if event.Weather == nil && event.Daytimer == nil {
+		entry := &loxone.RecordEntry{UUID: event.UUID, Value: event.Value}
+		if event.Text != nil {
+			entry.Text = &event.Text.Value
+		}
+		s.record(entry)
+	}
+	s.upState.alive()
+	eventsReceived.WithLabelValues(s.miniserver.Name).Inc()
+	lastEvent.WithLabelValues(s.miniserver.Name).SetToCurrentTime()
+
+	if event.Weather != nil {
+		s.updateWeather(event.Weather)
+		return
+	}
+	eventMetric, ok := s.states[event.UUID]
+	if !ok {
+		unknownEvents.WithLabelValues(s.miniserver.Name).Inc()
+		unknownLog.add(s.miniserver.Name, event.UUID, event.Value)
+		s.log.Debugf("event unknown: %+v\n", event)
+		return
+	}
+	eventsByType.WithLabelValues(s.miniserver.Name, (*eventMetric.labels)["type"]).Inc()
+	if event.Text != nil {
+		eventMetric.setText(event.Text.Value)
+		return
+	}
+	if event.Daytimer != nil {
+		eventMetric.setSchedule(newDaytimerSchedule(event.Daytimer, s.structure.OperatingModes))
+		return
+	}
+	if s.pool != nil {
+		s.pool.submit(event.UUID, eventMetric, event.Value)
+		return
+	}
+	s.process(eventMetric, event.Value)
+}
+
+// process updates the state with the value of an event
+func (s *session) process(state *eventMetric, value float64) {
+	start := time.Now()
+	state.update(value)
+	if s.cfg.EventHistograms {
+		eventProcessing.WithLabelValues(s.miniserver.Name, (*state.labels)["type"]).Observe(time.Since(start).Seconds())
+	}
+}
+
+// releaseLater returns the function the rate limiter of a state calls from
+// its timer, it hands the state back to the read loop
+func (s *session) releaseLater(uuid string, state *eventMetric) func() {
+	return func() {
+		select {
+		case s.released <- heldState{uuid: uuid, state: state}:
+		case <-s.done:
+		}
+	}
+}
+
+// release applies the value held back by the rate limit of a state, in the
+// worker of the state like its events
+func (s *session) release(held heldState) {
+	if s.pool != nil {
+		s.pool.submitRelease(held.uuid, held.state)
+		return
+	}
+	held.state.release()
+}
+
+// miniserverFile is a configured file of a Miniserver, with several
+// Miniservers their name is added before the extension
+func miniserverFile(cfg *config.Config, file string, miniserver string) string {
+	if len(cfg.Miniservers) < 2 {
+		return file
+	}
+	ext := filepath.Ext(file)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(file, ext), miniserver, ext)
+}
+
+// record appends an entry to the recording, if recording
+func (s *session) record(entry *loxone.RecordEntry) {
+	if s.recorder == nil {
+		return
+	}
+	entry.Time = time.Now()
+	err := s.recorder.Write(entry)
+	if err != nil {
+		s.log.Errorf("Unable to record: %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Sink receives every state update, to forward it to another system than
+// Prometheus. Publish is called while events are processed and must not block.
+type Sink interface {
+	Publish(labels prometheus.Labels, value float64, at time.Time)
+}
+
+// TextSink receives the texts of text states, like Sink it must not block
+type TextSink interface {
+	PublishText(labels prometheus.Labels, text string, at time.Time)
+}
+
+// AddSink forwards the updates of the states mapped from now on to the sink
+func (m *StateMapper) AddSink(sink Sink) {
+	m.Lock()
+	defer m.Unlock()
+	m.sinks = append(m.sinks, sink)
+}
+
+// AddTextSink forwards the texts of the text states mapped from now on to the sink
+func (m *StateMapper) AddTextSink(sink TextSink) {
+	m.Lock()
+	defer m.Unlock()
+	m.textSinks = append(m.textSinks, sink)
+}
@@ -0,0 +1,586 @@
+package collector
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	"github.com/bep/debounce"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// startupReport summarizes how the structure file was mapped to series
+type startupReport struct {
+	Controls        int            `json:"controls"`
+	Kept            int            `json:"kept"`
+	Filtered        map[string]int `json:"filtered"`
+	DuplicateUUIDs  []string       `json:"duplicate_uuids"`
+	SkippedControls []string       `json:"skipped_controls"`
+	// UnsupportedStates count the states that are neither a UUID nor an array of UUIDs, by type.state
+	UnsupportedStates map[string]int `json:"unsupported_states"`
+	Series            int            `json:"series"`
+	// controls are all controls of the structure file, for the controls page
+	controls []*controlEntry
+}
+
+func (r *startupReport) write(file string) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, content, 0644)
+}
+
+// StateMapper turns the structure file into the state map, its filters and
+// relabel rules can be reloaded
+type StateMapper struct {
+	sync.RWMutex
+	cfg     *config.Config
+	floors  *floorMapper
+	filter  *controlFilter
+	relabel []*relabelRule
+	// debounce is the interval of the controls without override
+	debounce           time.Duration
+	debounceOverrides  []config.DebounceConfig
+	dormancyOverrides  []config.DormancyConfig
+	rateLimitOverrides []config.RateLimitConfig
+	valueMappings      []config.ValueMapping
+	transforms         []config.Transform
+	metricTypes        []config.MetricType
+	histogramStates    []config.HistogramState
+	colorStates        []config.ColorState
+	normalize          []config.NormalizeConfig
+	names              config.NamesConfig
+	eventLog           *eventLogLimiter
+	recent             *eventRing
+	sinks              []Sink
+	textSinks          []TextSink
+	changed            chan struct{}
+}
+
+// NewStateMapper compiles the floor rules, filters and relabel rules of the config
+func NewStateMapper(cfg *config.Config) (*StateMapper, error) {
+	floors, err := newFloorMapper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := newControlFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := compileRelabelRules(cfg.Relabel)
+	if err != nil {
+		return nil, err
+	}
+	return &StateMapper{
+		cfg:                cfg,
+		floors:             floors,
+		filter:             filter,
+		relabel:            rules,
+		debounce:           cfg.Debounce,
+		debounceOverrides:  cfg.DebounceOverrides,
+		dormancyOverrides:  cfg.DormancyOverrides,
+		rateLimitOverrides: cfg.RateLimitOverrides,
+		valueMappings:      cfg.ValueMappings,
+		transforms:         cfg.Transforms,
+		metricTypes:        cfg.MetricTypes,
+		histogramStates:    cfg.ValueHistogramStates,
+		colorStates:        cfg.ColorStates,
+		normalize:          cfg.NormalizeLabels,
+		names:              cfg.Names,
+		eventLog:           newEventLogLimiter(cfg.Log.EventRate),
+		recent:             newEventRing(cfg.EventBuffer),
+		changed:            make(chan struct{}),
+	}, nil
+}
+
+// Reload takes the mapping settings of a new config, listed in the README,
+// the other settings need a restart
+func (m *StateMapper) Reload(cfg *config.Config) error {
+	filter, err := newControlFilter(cfg)
+	if err != nil {
+		return err
+	}
+	rules, err := compileRelabelRules(cfg.Relabel)
+	if err != nil {
+		return err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	m.filter, m.relabel, m.normalize, m.names = filter, rules, cfg.NormalizeLabels, cfg.Names
+	m.debounce, m.debounceOverrides, m.dormancyOverrides = cfg.Debounce, cfg.DebounceOverrides, cfg.DormancyOverrides
+	m.rateLimitOverrides = cfg.RateLimitOverrides
+	m.valueMappings, m.transforms, m.metricTypes = cfg.ValueMappings, cfg.Transforms, cfg.MetricTypes
+	m.histogramStates, m.colorStates = cfg.ValueHistogramStates, cfg.ColorStates
+	close(m.changed)
+	m.changed = make(chan struct{})
+	return nil
+}
+
+// debounceInterval returns the debounce interval of a control, an override
+// of the control wins over one of its type
+func debounceInterval(interval time.Duration, overrides []config.DebounceConfig, labels prometheus.Labels) time.Duration {
+	byType := -1
+	for i, override := range overrides {
+		if override.Control != "" && override.Control == labels["control"] {
+			return override.Interval
+		}
+		if byType < 0 && override.Control == "" && override.Type == labels["type"] {
+			byType = i
+		}
+	}
+	if byType >= 0 {
+		return overrides[byType].Interval
+	}
+	return interval
+}
+
+// dormancyWindow returns the dormancy window of a control, an override of the
+// control wins over one of its type
+func dormancyWindow(window time.Duration, overrides []config.DormancyConfig, labels prometheus.Labels) time.Duration {
+	byType := -1
+	for i, override := range overrides {
+		if override.Control != "" && override.Control == labels["control"] {
+			return override.Window
+		}
+		if byType < 0 && override.Control == "" && override.Type == labels["type"] {
+			byType = i
+		}
+	}
+	if byType >= 0 {
+		return overrides[byType].Window
+	}
+	return window
+}
+
+// changes is closed on the next reload
+func (m *StateMapper) changes() <-chan struct{} {
+	m.RLock()
+	defer m.RUnlock()
+	return m.changed
+}
+
+// build maps every state UUID of the structure file to its metric
+func (m *StateMapper) build(loxoneConfig *loxone.Structure, miniserver string) (map[string]*eventMetric, *startupReport) {
+	m.RLock()
+	cfg, floors, filter, rules, normalize, names := m.cfg, m.floors, m.filter, m.relabel, m.normalize, m.names
+	interval, overrides, dormancyOverrides := m.debounce, m.debounceOverrides, m.dormancyOverrides
+	rateLimitOverrides := m.rateLimitOverrides
+	mappings, transforms, metricTypes, histogramStates := m.valueMappings, m.transforms, m.metricTypes, m.histogramStates
+	colorStates := m.colorStates
+	eventLog, recent, sinks, textSinks := m.eventLog, m.recent, m.sinks, m.textSinks
+	m.RUnlock()
+
+	globalStates := make(map[string]*eventMetric)
+	report := &startupReport{
+		Controls:          len(loxoneConfig.Controls),
+		Filtered:          make(map[string]int),
+		DuplicateUUIDs:    make([]string, 0),
+		SkippedControls:   make([]string, 0),
+		UnsupportedStates: make(map[string]int),
+		controls:          make([]*controlEntry, 0, len(loxoneConfig.Controls)),
+	}
+
+	// add maps the state, it returns nil if relabeling dropped it
+	add := func(uuid string, labels prometheus.Labels) *eventMetric {
+		stable := debounceInterval(interval, overrides, labels)
+		dormancy := dormancyWindow(cfg.DormancyWindow, dormancyOverrides, labels)
+		rate := rateLimit(cfg.RateLimit, rateLimitOverrides, labels)
+		histogram := valueHistogram != nil && histogramOf(histogramStates, labels["type"], labels["control"], labels["state"])
+		normalizeLabels(normalize, labels)
+		if !relabel(rules, labels) {
+			report.Filtered["relabel"]++
+			return nil
+		}
+		if _, ok := globalStates[uuid]; ok {
+			report.DuplicateUUIDs = append(report.DuplicateUUIDs, uuid)
+		}
+		truncateLabels(labels, cfg.MaxLabelLength)
+		state := newEventMetric(&labels, cfg, stable)
+		state.dormancyWindow = dormancy
+		state.limiter = newRateLimiter(rate)
+		state.histogram = histogram
+		state.eventLog = eventLog
+		state.recent = recent
+		state.sinks = sinks
+		state.textSinks = textSinks
+		globalStates[uuid] = state
+		return state
+	}
+
+	// lightCategory tells whether the control being mapped is in a category of lights
+	lightCategory := false
+
+	// mapStates maps the states of a control or subcontrol, it returns how many
+	mapStates := func(controlType string, controlName string, states map[string]interface{}, details loxone.ControlDetails, labels prometheus.Labels) int {
+		mapped := 0
+		for stateName, stateValue := range states {
+			// Can be a string or an array of strings...
+			switch stateValue := stateValue.(type) {
+			case string:
+				// Create the target map
+				currentLabel := prometheus.Labels{}
+				for key, value := range labels {
+					currentLabel[key] = value
+				}
+				currentLabel["state"] = stateName
+				state := add(stateValue, currentLabel)
+				mapped++
+
+				if state != nil {
+					state.valueNames = valueNames(mappings, controlType, controlName, stateName)
+					state.transform = transformOf(transforms, controlType, controlName, stateName)
+					state.color = colorOf(colorStates, controlType, controlName, stateName)
+				}
+				if state != nil && isMeterTotal(controlType, stateName) {
+					state.meter = true
+				}
+				if state != nil {
+					switch metricTypeOf(metricTypes, controlType, controlName, stateName) {
+					case metricTypeGauge:
+						state.meter = false
+					case metricTypeCounter:
+						state.meter = true
+					case metricTypeStateSet:
+						state.stateSet = true
+						if state.valueNames == nil {
+							state.valueNames = booleanNames
+						}
+					}
+				}
+				if state != nil && (cfg.TypedMetrics || cfg.Aggregates) {
+					state.unit = unitOf(details.StateFormat(stateName))
+				}
+				if state != nil && cfg.Aggregates && lightCategory {
+					state.light = isLightState(controlType, stateName)
+				}
+				if state != nil && cfg.AccessMetrics {
+					if hook := accessHook(controlType, controlName, stateName); hook != nil {
+						state.hooks = append(state.hooks, hook)
+					}
+				}
+				if state != nil && cfg.SecurityMetrics && isAlarmControl(controlType) {
+					if hook := alarmHook(controlType, controlName, stateName); hook != nil {
+						state.hooks = append(state.hooks, hook)
+					}
+				}
+			case []interface{}:
+				for index, childStateValue := range stateValue {
+					childUUID, ok := childStateValue.(string)
+					if !ok {
+						report.UnsupportedStates[controlType+"."+stateName]++
+						continue
+					}
+					// Create the target map
+					currentLabel := prometheus.Labels{}
+					for key, value := range labels {
+						currentLabel[key] = value
+					}
+					child := childName(cfg.ArrayStateNames, controlType, stateName, index)
+					if cfg.ArrayChildLabels {
+						currentLabel["state"] = stateName
+						currentLabel["element"] = child
+					} else {
+						currentLabel["state"] = stateName + "-" + child
+					}
+					add(childUUID, currentLabel)
+					mapped++
+				}
+			default:
+				report.UnsupportedStates[controlType+"."+stateName]++
+			}
+		}
+		return mapped
+	}
+
+	// mapSubControls maps the states of subcontrols recursively, their
+	// subcontrol label is the path of their names
+	var mapSubControls func(controlName string, subControls map[string]*loxone.SubControl, prefix string, labels prometheus.Labels) int
+	mapSubControls = func(controlName string, subControls map[string]*loxone.SubControl, prefix string, labels prometheus.Labels) int {
+		mapped := 0
+		for _, subControl := range subControls {
+			subLabels := prometheus.Labels{}
+			for key, value := range labels {
+				subLabels[key] = value
+			}
+			subLabels["subcontrol"] = prefix + subControl.Name
+			mapped += mapStates(subControl.Type, controlName, subControl.States, subControl.Details, subLabels)
+			mapped += mapSubControls(controlName, subControl.SubControls, subLabels["subcontrol"]+"/", subLabels)
+		}
+		return mapped
+	}
+
+	if cfg.ControlInfo {
+		controlInfo.DeletePartialMatch(prometheus.Labels{"miniserver": miniserver})
+	}
+	if cfg.ControlMetadata {
+		controlMetadata.DeletePartialMatch(prometheus.Labels{"miniserver": miniserver})
+	}
+
+	for uuid, control := range loxoneConfig.Controls {
+
+		labels := map[string]string{
+			"miniserver": miniserver,
+			"control":    control.Name,
+			"room":       loxoneConfig.RoomName(control.Room),
+			"type":       control.Type,
+			"cat":        loxoneConfig.CatName(control.Cat),
+			"state":      "",
+		}
+		entry := newControlEntry(uuid, control, loxoneConfig, cfg.ArrayStateNames)
+		report.controls = append(report.controls, entry)
+		if filter := filter.match(labels, uuid); filter != "" {
+			report.Filtered[filter]++
+			entry.filtered = filter
+			continue
+		}
+		if cfg.ArrayChildLabels {
+			labels["element"] = ""
+		}
+		if floors != nil {
+			labels["floor"] = floors.floor(labels["room"])
+		}
+		if name, ok := nameOverride(names.Controls, uuid); ok {
+			labels["control"] = name
+		}
+		if name, ok := nameOverride(names.Rooms, control.Room); ok {
+			labels["room"] = name
+		}
+
+		if cfg.SubControls {
+			labels["subcontrol"] = ""
+		}
+		if cfg.UUIDLabels {
+			labels["control_uuid"] = uuid
+			labels["room_uuid"] = control.Room
+		}
+
+		lightCategory = isLightCategory(loxoneConfig, control.Cat)
+		mapped := mapStates(control.Type, control.Name, control.States, loxoneConfig.Details[uuid], labels)
+		if cfg.SubControls {
+			mapped += mapSubControls(control.Name, loxoneConfig.SubControls[uuid], "", labels)
+		}
+
+		if cfg.ControlInfo {
+			normalizeLabels(normalize, labels)
+			controlInfo.WithLabelValues(
+				miniserver,
+				truncateLabel(labels["control"], cfg.MaxLabelLength),
+				truncateLabel(labels["room"], cfg.MaxLabelLength),
+				truncateLabel(labels["cat"], cfg.MaxLabelLength),
+				truncateLabel(labels["type"], cfg.MaxLabelLength),
+				uuid,
+			).Set(1)
+		}
+		if cfg.ControlMetadata {
+			normalizeLabels(normalize, labels)
+			setControlMetadata(loxoneConfig, miniserver, uuid, truncateLabel(labels["control"], cfg.MaxLabelLength))
+		}
+
+		if mapped == 0 {
+			report.SkippedControls = append(report.SkippedControls, control.Name)
+			continue
+		}
+		report.Kept++
+	}
+
+	for stateName, stateValue := range loxoneConfig.GlobalStates {
+		labels := prometheus.Labels{
+			"miniserver": miniserver,
+			"control":    "global",
+			"room":       "global",
+			"type":       "global",
+			"cat":        "global",
+			"state":      stateName,
+		}
+		if filter := filter.match(labels, stateValue); filter != "" {
+			report.Filtered[filter]++
+			continue
+		}
+		if cfg.ArrayChildLabels {
+			labels["element"] = ""
+		}
+		if floors != nil {
+			labels["floor"] = "global"
+		}
+		if cfg.SubControls {
+			labels["subcontrol"] = ""
+		}
+		if cfg.UUIDLabels {
+			labels["control_uuid"] = ""
+			labels["room_uuid"] = ""
+		}
+		add(stateValue, labels)
+	}
+
+	report.Series = len(globalStates)
+	return globalStates, report
+}
+
+type eventMetric struct {
+	sync.Mutex
+	labels           *prometheus.Labels
+	initialized      bool
+	value            float64
+	lastEvent        time.Time
+	debounceFunction func(f func())
+	hooks            []func(float64)
+	unit             *unit
+	// eventLog limits the logged events of the control
+	eventLog *eventLogLimiter
+	// recent keeps the last events for /api/v1/events, if set
+	recent *eventRing
+	// sinks receive every update, textSinks every text
+	sinks     []Sink
+	textSinks []TextSink
+	// valueNames name the values for loxone_state_info
+	valueNames []config.ValueName
+	// transform rewrites the values of events, if set
+	transform *config.Transform
+	// dormancyWindow leaves the value out of the scrape once the state had no event for that long, 0 keeps it
+	dormancyWindow time.Duration
+	// histogram records the values in loxone_value_histogram
+	histogram bool
+	// limiter holds back events above the rate limit of the state, if set
+	limiter *rateLimiter
+	// changes are counted after debouncing, lastChange is when the last one was counted
+	changes    float64
+	lastChange time.Time
+	// meter totals are exported as loxone_meter_total, reset is when
+	// they last went down
+	meter bool
+	reset time.Time
+	// stateSet states export every value name in loxone_stateset
+	stateSet bool
+	// color is the encoding of packed colors, unpacked in loxone_color_channel
+	color string
+	// light tells the state is on above 0, for loxone_room_lights_on
+	light bool
+	// text is the last text of text states, for loxone_text_info
+	text    string
+	hasText bool
+	// schedule is the last schedule of daytimer states, for loxone_daytimer_*
+	schedule *daytimerSchedule
+	cfg      *config.Config
+}
+
+func newEventMetric(labels *prometheus.Labels, cfg *config.Config, interval time.Duration) *eventMetric {
+	e := &eventMetric{
+		initialized: false,
+		labels:      labels,
+		cfg:         cfg,
+	}
+	if interval > 0 {
+		e.debounceFunction = debounce.New(interval)
+	}
+	return e
+}
+
+// carryOver takes the value of the same state from a previous map
+func (e *eventMetric) carryOver(previous *eventMetric) {
+	previous.Lock()
+	defer previous.Unlock()
+	e.value, e.lastEvent, e.reset = previous.value, previous.lastEvent, previous.reset
+	e.changes, e.lastChange = previous.changes, previous.lastChange
+	e.initialized = previous.initialized
+	e.text, e.hasText = previous.text, previous.hasText
+	e.schedule = previous.schedule
+}
+
+// setText keeps the text of a text event and forwards it to the text sinks
+func (e *eventMetric) setText(text string) {
+	e.Lock()
+	e.text, e.hasText = text, true
+	e.Unlock()
+
+	now := time.Now()
+	for _, sink := range e.textSinks {
+		sink.PublishText(*e.labels, text, now)
+	}
+}
+
+// update applies the value of an event, unless the rate limit holds it back
+func (e *eventMetric) update(value float64) {
+	if e.limiter != nil && !e.limiter.allow(value) {
+		eventsRateLimited.WithLabelValues((*e.labels)["miniserver"]).Inc()
+		return
+	}
+	e.apply(value)
+}
+
+// release applies the value held back by the rate limit, if it's still pending
+func (e *eventMetric) release() {
+	if value, ok := e.limiter.take(); ok {
+		e.apply(value)
+	}
+}
+
+func (e *eventMetric) apply(value float64) {
+	now := time.Now()
+	if e.transform != nil {
+		value = transformValue(e.transform, value)
+	}
+	e.Lock()
+	if e.cfg.Dedup && e.initialized && math.Abs(value-e.value) <= e.cfg.DedupEpsilon {
+		// The state is alive but nothing changed, only the time of the event counts
+		e.lastEvent = now
+		e.Unlock()
+		eventsDeduplicated.WithLabelValues((*e.labels)["miniserver"]).Inc()
+		return
+	}
+	if e.meter && e.initialized && value < e.value {
+		log.Infof("Meter %+v was reset from %f to %f", e.labels, e.value, value)
+		e.reset = time.Now()
+	}
+	e.value = value
+	previousEvent := e.lastEvent
+	e.lastEvent = now
+	// The first value only initializes the state, it isn't counted as a change
+	first := !e.initialized
+	e.initialized = true
+	e.Unlock()
+
+	if e.cfg.EventHistograms && !previousEvent.IsZero() {
+		eventInterval.WithLabelValues((*e.labels)["miniserver"], (*e.labels)["type"]).Observe(now.Sub(previousEvent).Seconds())
+	}
+
+	if e.histogram {
+		valueHistogram.With(seriesLabels(*e.labels)).Observe(value)
+	}
+	for _, hook := range e.hooks {
+		hook(value)
+	}
+	for _, sink := range e.sinks {
+		sink.Publish(*e.labels, value, now)
+	}
+	if e.recent != nil {
+		e.recent.add(*e.labels, value, now)
+	}
+
+	if first {
+		return
+	}
+
+	if log.IsLevelEnabled(log.DebugLevel) && e.eventLog.allow((*e.labels)["miniserver"]+"/"+(*e.labels)["control"]) {
+		log.Debugf("New event %+v with value %f", e.labels, value)
+	}
+
+	count := func() {
+		e.Lock()
+		defer e.Unlock()
+		e.changes++
+		e.lastChange = time.Now()
+	}
+	if e.debounceFunction == nil {
+		count()
+		return
+	}
+	e.debounceFunction(count)
+}
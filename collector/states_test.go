@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// testStructure has a room controller with an array state and a switch
+const testStructure = `{
+	"lastModified": "2024-01-01 00:00:00",
+	"rooms": {"r1": {"name": "Living room", "uuid": "r1"}},
+	"cats": {"c1": {"name": "Climate", "uuid": "c1", "type": "indoortemperature"}},
+	"controls": {
+		"a1": {"name": "Heating", "type": "IRoomController", "room": "r1", "cat": "c1",
+			"states": {"tempActual": "s1", "temperatures": ["t0", "t1"]}},
+		"a2": {"name": "Fan", "type": "Switch", "room": "r1", "cat": "c1",
+			"states": {"active": "s2", "speeds": ["f0"]}}
+	}
+}`
+
+// buildStates maps a structure file with the mapper of cfg
+func buildStates(t *testing.T, cfg *config.Config, structure string) map[string]*eventMetric {
+	t.Helper()
+	parsed, err := loxone.ParseStructure([]byte(structure))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapper, err := NewStateMapper(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	states, _ := mapper.build(parsed, "home")
+	return states
+}
+
+// metricValue reads the value of a gauge or counter
+func metricValue(t *testing.T, metric prometheus.Metric) float64 {
+	t.Helper()
+	pb := &dto.Metric{}
+	err := metric.Write(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pb.Counter != nil {
+		return pb.GetCounter().GetValue()
+	}
+	return pb.GetGauge().GetValue()
+}
+
+func TestArrayChildLabels(t *testing.T) {
+	tests := []struct {
+		name        string
+		childLabels bool
+		// states and elements are the labels expected by state UUID
+		states   map[string]string
+		elements map[string]string
+	}{
+		{
+			name:     "suffixed state",
+			states:   map[string]string{"s1": "tempActual", "t0": "temperatures-economy", "t1": "temperatures-comfort_heating", "f0": "speeds-0"},
+			elements: map[string]string{},
+		},
+		{
+			name:        "element label",
+			childLabels: true,
+			states:      map[string]string{"s1": "tempActual", "t0": "temperatures", "t1": "temperatures", "f0": "speeds"},
+			elements:    map[string]string{"s1": "", "t0": "economy", "t1": "comfort_heating", "f0": "0"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			states := buildStates(t, &config.Config{ArrayChildLabels: test.childLabels}, testStructure)
+			for uuid, want := range test.states {
+				state, ok := states[uuid]
+				if !ok {
+					t.Fatalf("state %s isn't mapped", uuid)
+				}
+				labels := *state.labels
+				if labels["state"] != want {
+					t.Errorf("state %s has state label %q, want %q", uuid, labels["state"], want)
+				}
+				element, hasElement := labels["element"]
+				if !test.childLabels && hasElement {
+					t.Errorf("state %s has an element label without --array-child-labels", uuid)
+				}
+				if test.childLabels && (!hasElement || element != test.elements[uuid]) {
+					t.Errorf("state %s has element label %q, want %q", uuid, element, test.elements[uuid])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// MiniserverStatus sums up the state of a Miniserver
+type MiniserverStatus struct {
+	Name          string
+	Connected     bool
+	Controls      int
+	Series        int
+	UnknownEvents float64
+}
+
+// Status returns the status of every Miniserver the exporter connected to
+// or tries to connect to, by name
+func (c *ValuesCollector) Status() []MiniserverStatus {
+	names := make(map[string]bool)
+	connections.RLock()
+	for name := range connections.states {
+		names[name] = true
+	}
+	connections.RUnlock()
+
+	c.RLock()
+	for name := range c.miniservers {
+		names[name] = true
+	}
+	result := make([]MiniserverStatus, 0, len(names))
+	for name := range names {
+		status := MiniserverStatus{Name: name, Connected: IsConnected(name)}
+		if m, ok := c.miniservers[name]; ok && m.active {
+			status.Controls, status.Series = len(m.controls), len(m.states)
+		}
+		metric := &dto.Metric{}
+		if unknownEvents.WithLabelValues(name).Write(metric) == nil {
+			status.UnknownEvents = metric.GetCounter().GetValue()
+		}
+		result = append(result, status)
+	}
+	c.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
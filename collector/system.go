@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	miniserverCPU = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_miniserver_cpu_percent",
+			Help: "CPU load of the Miniserver",
+		},
+		[]string{"miniserver"},
+	)
+	miniserverHeap = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_miniserver_heap_bytes",
+			Help: "Heap used by the Miniserver",
+		},
+		[]string{"miniserver"},
+	)
+	miniserverTasks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_miniserver_tasks",
+			Help: "Number of tasks running on the Miniserver",
+		},
+		[]string{"miniserver"},
+	)
+)
+
+// pollSystemStats exports the system statistics of the Miniserver every
+// interval until the context is done
+func (s *session) pollSystemStats(ctx context.Context, lox *loxone.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var stats *loxone.SystemStats
+		err := s.request(endpointSystem, func() (err error) {
+			stats, err = lox.SystemStats()
+			return err
+		})
+		if err != nil {
+			s.log.Warnf("Unable to read the system statistics: %v", err)
+		} else {
+			miniserverCPU.WithLabelValues(s.miniserver.Name).Set(stats.CPUPercent)
+			miniserverHeap.WithLabelValues(s.miniserver.Name).Set(stats.HeapBytes)
+			miniserverTasks.WithLabelValues(s.miniserver.Name).Set(stats.Tasks)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package collector
+
+// textSeries is the text of the text states exported with the same labels
+type textSeries struct {
+	labelValues []string
+	text        string
+	color       string
+}
+
+// texts returns the last texts of the text states, the first state wins
+// when several are left with the same labels
+func (c *ValuesCollector) texts() []*textSeries {
+	result := make([]*textSeries, 0)
+	seen := make(map[string]bool)
+	for _, state := range c.allStates() {
+		state.Lock()
+		text, hasText := state.text, state.hasText
+		state.Unlock()
+		if !hasText {
+			continue
+		}
+
+		key := labelsKey(*state.labels)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, &textSeries{labelValues: seriesLabelValues(*state.labels), text: text, color: state.color})
+	}
+	return result
+}
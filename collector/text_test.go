@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	"github.com/XciD/loxone-ws/events"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestTextEvents(t *testing.T) {
+	cfg := &config.Config{}
+	parsed, err := loxone.ParseStructure([]byte(testStructure))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapper, err := NewStateMapper(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := NewValuesCollector(cfg)
+	s := newSession(cfg, config.MiniserverConfig{Name: "home"}, mapper, values, newConnectionState("home", 0))
+	s.mapStructure(parsed, prunableVectors(cfg))
+
+	s.handleEvent(&events.Event{UUID: "s2", Text: &events.Text{Value: "first"}})
+	s.handleEvent(&events.Event{UUID: "s2", Text: &events.Text{Value: "second"}})
+
+	ch := make(chan prometheus.Metric, 100)
+	values.Collect(ch)
+	close(ch)
+	var texts []string
+	for metric := range ch {
+		name := metric.Desc().String()
+		if strings.Contains(name, `"loxone_values"`) {
+			t.Errorf("text state exported in loxone_values")
+		}
+		if !strings.Contains(name, `"loxone_text_info"`) {
+			continue
+		}
+		pb := &dto.Metric{}
+		if err := metric.Write(pb); err != nil {
+			t.Fatal(err)
+		}
+		for _, pair := range pb.GetLabel() {
+			if pair.GetName() == "value" {
+				texts = append(texts, pair.GetValue())
+			}
+		}
+	}
+	if !reflect.DeepEqual(texts, []string{"second"}) {
+		t.Errorf("loxone_text_info values are %v, want the last text", texts)
+	}
+}
+
+func TestTextColorChannels(t *testing.T) {
+	tests := []struct {
+		text string
+		want []colorChannel
+	}{
+		{"hsv(120,100,50)", []colorChannel{{"red", 0}, {"green", 50}, {"blue", 0}}},
+		{"hsv(0,0,100)", []colorChannel{{"red", 100}, {"green", 100}, {"blue", 100}}},
+		{"temp(80,2700)", []colorChannel{{"brightness", 80}, {"temperature", 2700}}},
+		{"Living room", nil},
+	}
+	for _, test := range tests {
+		got := textColorChannels(test.text)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: channels %v, want %v", test.text, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/loxone"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// tokenCheckInterval is how often the lifetime of the token is checked
+var tokenCheckInterval = 30 * time.Second
+
+// minTokenRefresh keeps tokens with a short lifetime from being refreshed in
+// a loop
+const minTokenRefresh = 10 * time.Second
+
+var (
+	tokenRefreshes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loxone_token_refreshes_total",
+			Help: "Number of times the token was refreshed before it expired",
+		},
+		[]string{"miniserver"},
+	)
+	tokenExpiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_token_expiry_seconds",
+			Help: "Seconds until the token of the connection expires",
+		},
+		[]string{"miniserver"},
+	)
+)
+
+// refreshDue tells whether a token valid until validUntil, with a lifetime
+// of lifetime at the login, needs a refresh at now. It's refreshed once half
+// of its lifetime is over.
+func refreshDue(now, validUntil time.Time, lifetime time.Duration) bool {
+	half := lifetime / 2
+	if half < minTokenRefresh {
+		half = minTokenRefresh
+	}
+	return validUntil.Sub(now) <= half
+}
+
+// keepToken refreshes the token of the connection before it expires until
+// the context is done, it returns the error of a failed refresh so the
+// session reconnects with a new token
+func (s *session) keepToken(ctx context.Context, lox *loxone.Client) error {
+	name := s.miniserver.Name
+	validUntil := lox.TokenValidUntil()
+	if validUntil.IsZero() {
+		s.log.Warn("The Miniserver didn't tell the lifetime of the token, it isn't refreshed")
+		return nil
+	}
+	defer tokenExpiry.DeleteLabelValues(name)
+	lifetime := time.Until(validUntil)
+	// Make the counter visible before the first refresh
+	tokenRefreshes.WithLabelValues(name)
+
+	ticker := time.NewTicker(tokenCheckInterval)
+	defer ticker.Stop()
+	for {
+		tokenExpiry.WithLabelValues(name).Set(time.Until(validUntil).Seconds())
+		if refreshDue(time.Now(), validUntil, lifetime) {
+			err := s.request(endpointToken, lox.RefreshToken)
+			if err != nil {
+				return fmt.Errorf("token refresh failed: %v", err)
+			}
+			tokenRefreshes.WithLabelValues(name).Inc()
+			validUntil = lox.TokenValidUntil()
+			s.log.Infof("Token refreshed, valid until %s", validUntil.Format(time.RFC3339))
+			tokenExpiry.WithLabelValues(name).Set(time.Until(validUntil).Seconds())
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshDue(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		left     time.Duration
+		lifetime time.Duration
+		want     bool
+	}{
+		{left: 20 * 24 * time.Hour, lifetime: 30 * 24 * time.Hour, want: false},
+		{left: 14 * 24 * time.Hour, lifetime: 30 * 24 * time.Hour, want: true},
+		{left: time.Minute, lifetime: time.Hour, want: true},
+		{left: 30 * time.Second, lifetime: 5 * time.Second, want: false},
+		{left: 5 * time.Second, lifetime: 5 * time.Second, want: true},
+		{left: -time.Second, lifetime: time.Hour, want: true},
+	} {
+		got := refreshDue(now, now.Add(c.left), c.lifetime)
+		if got != c.want {
+			t.Errorf("%s left of %s: refresh due %v, want %v", c.left, c.lifetime, got, c.want)
+		}
+	}
+}
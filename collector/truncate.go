@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"crypto/sha1" // #nosec only used to tell truncated values apart
+	"encoding/hex"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const truncateHashLength = 6
+
+// truncateLabel shortens a label value to maxLength runes. Truncated values end
+// with an ellipsis and a short hash of the full value so they stay unique.
+func truncateLabel(value string, maxLength int) string {
+	runes := []rune(value)
+	if maxLength <= 0 || len(runes) <= maxLength {
+		return value
+	}
+
+	sum := sha1.Sum([]byte(value)) // #nosec
+	suffix := "…" + hex.EncodeToString(sum[:])[:truncateHashLength]
+
+	keep := maxLength - len([]rune(suffix))
+	if keep < 0 {
+		keep = 0
+	}
+	return string(runes[:keep]) + suffix
+}
+
+func truncateLabels(labels prometheus.Labels, maxLength int) {
+	for name, value := range labels {
+		labels[name] = truncateLabel(value, maxLength)
+	}
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+func TestTruncateLabel(t *testing.T) {
+	tests := []struct {
+		value     string
+		maxLength int
+		want      string
+	}{
+		{"Living room", 0, "Living room"},
+		{"Living room", 11, "Living room"},
+		{"Küche", 5, "Küche"},
+	}
+	for _, test := range tests {
+		if got := truncateLabel(test.value, test.maxLength); got != test.want {
+			t.Errorf("truncateLabel(%q, %d) = %q, want %q", test.value, test.maxLength, got, test.want)
+		}
+	}
+}
+
+func TestTruncateLabelKeepsValuesApart(t *testing.T) {
+	values := []string{
+		"Temperature sensor living room north",
+		"Temperature sensor living room south",
+		"Temperature sensor living room",
+		"Temperatursensor Wohnzimmer Süd-Ost",
+	}
+	for _, maxLength := range []int{config.MinLabelLength, 20, 30} {
+		seen := make(map[string]string)
+		for _, value := range values {
+			truncated := truncateLabel(value, maxLength)
+			if length := utf8.RuneCountInString(truncated); length > maxLength {
+				t.Errorf("truncateLabel(%q, %d) has %d runes", value, maxLength, length)
+			}
+			if utf8.RuneCountInString(value) > maxLength && !strings.Contains(truncated, "…") {
+				t.Errorf("truncateLabel(%q, %d) = %q has no ellipsis", value, maxLength, truncated)
+			}
+			if other, ok := seen[truncated]; ok {
+				t.Errorf("%q and %q are both truncated to %q", other, value, truncated)
+			}
+			seen[truncated] = value
+		}
+	}
+
+	// The same value is always truncated the same way
+	if truncateLabel(values[0], 20) != truncateLabel(values[0], 20) {
+		t.Error("truncation isn't stable")
+	}
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unit is a typed metric a state is exported to, next to loxone_values
+type unit struct {
+	// name of the metric without the loxone_ prefix
+	name      string
+	help      string
+	scale     float64
+	valueType prometheus.ValueType
+}
+
+var (
+	celsius    = &unit{"temperature_celsius", "Temperature in degrees Celsius", 1, prometheus.GaugeValue}
+	watts      = &unit{"power_watts", "Power in watts", 1, prometheus.GaugeValue}
+	kilowatts  = &unit{"power_watts", "Power in watts", 1000, prometheus.GaugeValue}
+	wattHours  = &unit{"energy_kwh_total", "Energy in kilowatt hours", 0.001, prometheus.CounterValue}
+	kwh        = &unit{"energy_kwh_total", "Energy in kilowatt hours", 1, prometheus.CounterValue}
+	lux        = &unit{"illuminance_lux", "Illuminance in lux", 1, prometheus.GaugeValue}
+	volts      = &unit{"voltage_volts", "Voltage in volts", 1, prometheus.GaugeValue}
+	amperes    = &unit{"current_amperes", "Current in amperes", 1, prometheus.GaugeValue}
+	hertz      = &unit{"frequency_hertz", "Frequency in hertz", 1, prometheus.GaugeValue}
+	hpa        = &unit{"pressure_pascals", "Pressure in pascals", 100, prometheus.GaugeValue}
+	ppm        = &unit{"concentration_ppm", "Concentration in parts per million", 1, prometheus.GaugeValue}
+	metersPerS = &unit{"speed_meters_per_second", "Speed in meters per second", 1, prometheus.GaugeValue}
+	kmPerH     = &unit{"speed_meters_per_second", "Speed in meters per second", 1 / 3.6, prometheus.GaugeValue}
+)
+
+// units maps the unit part of a Loxone format to its typed metric
+var units = map[string]*unit{
+	"°":    celsius,
+	"°C":   celsius,
+	"W":    watts,
+	"kW":   kilowatts,
+	"Wh":   wattHours,
+	"kWh":  kwh,
+	"lx":   lux,
+	"V":    volts,
+	"A":    amperes,
+	"Hz":   hertz,
+	"hPa":  hpa,
+	"ppm":  ppm,
+	"m/s":  metersPerS,
+	"km/h": kmPerH,
+}
+
+// formatVerb matches the value placeholder of a format, %.1f or <v.1>
+var formatVerb = regexp.MustCompile(`%[-+ #0]*\d*(?:\.\d+)?[a-zA-Z]|<v(?:\.\d+)?>`)
+
+// unitOf returns the typed metric of a format like %.1f°, nil if the unit is unknown
+func unitOf(format string) *unit {
+	if format == "" {
+		return nil
+	}
+	return units[strings.TrimSpace(formatVerb.ReplaceAllString(format, ""))]
+}
+
+// unitDescs returns one Desc per typed metric
+func unitDescs() map[string]*prometheus.Desc {
+	descs := make(map[string]*prometheus.Desc)
+	for _, u := range units {
+		descs[u.name] = prometheus.NewDesc("loxone_"+u.name, u.help, seriesLabelNames, nil)
+	}
+	return descs
+}
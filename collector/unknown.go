@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxUnknownEvents bounds the UUIDs tracked, a Miniserver sending events of
+// many unknown UUIDs doesn't grow the exporter without limit
+const maxUnknownEvents = 10000
+
+// UnknownEvent is a UUID events arrived for that isn't mapped to a state
+type UnknownEvent struct {
+	Miniserver string    `json:"miniserver"`
+	UUID       string    `json:"uuid"`
+	Count      int       `json:"count"`
+	LastValue  float64   `json:"last_value"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// unknownEventLog holds the unknown UUIDs by Miniserver and UUID
+type unknownEventLog struct {
+	sync.Mutex
+	events map[[2]string]*UnknownEvent
+}
+
+var unknownLog = &unknownEventLog{events: make(map[[2]string]*UnknownEvent)}
+
+func (l *unknownEventLog) add(miniserver string, uuid string, value float64) {
+	l.Lock()
+	defer l.Unlock()
+	key := [2]string{miniserver, uuid}
+	event, ok := l.events[key]
+	if !ok {
+		if len(l.events) >= maxUnknownEvents {
+			return
+		}
+		event = &UnknownEvent{Miniserver: miniserver, UUID: uuid}
+		l.events[key] = event
+	}
+	event.Count++
+	event.LastValue, event.LastSeen = value, time.Now()
+}
+
+// forget drops the UUIDs of a Miniserver that are mapped now, e.g. after a
+// structure file change
+func (l *unknownEventLog) forget(miniserver string, states map[string]*eventMetric) {
+	l.Lock()
+	defer l.Unlock()
+	for key := range l.events {
+		if _, ok := states[key[1]]; ok && key[0] == miniserver {
+			delete(l.events, key)
+		}
+	}
+}
+
+// UnknownEvents returns the UUIDs events arrived for without a state, the most frequent first
+func UnknownEvents() []UnknownEvent {
+	unknownLog.Lock()
+	result := make([]UnknownEvent, 0, len(unknownLog.events))
+	for _, event := range unknownLog.events {
+		result = append(result, *event)
+	}
+	unknownLog.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].UUID < result[j].UUID
+	})
+	return result
+}
@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	loxonews "github.com/XciD/loxone-ws"
+)
+
+// ValidateConfig checks the mapping settings of the config without connecting
+// to anything, RegisterMetrics must have been called for the label names
+func ValidateConfig(cfg *config.Config) []error {
+	errs := make([]error, 0)
+	if _, err := NewStateMapper(cfg); err != nil {
+		errs = append(errs, err)
+	}
+
+	for i, override := range cfg.DebounceOverrides {
+		if override.Control == "" && override.Type == "" {
+			errs = append(errs, fmt.Errorf("debounce-overrides[%d]: needs a control or a type", i))
+		}
+		if override.Interval < 0 {
+			errs = append(errs, fmt.Errorf("debounce-overrides[%d]: negative interval %s", i, override.Interval))
+		}
+	}
+	for i, override := range cfg.DormancyOverrides {
+		if override.Control == "" && override.Type == "" {
+			errs = append(errs, fmt.Errorf("dormancy-overrides[%d]: needs a control or a type", i))
+		}
+		if override.Window < 0 {
+			errs = append(errs, fmt.Errorf("dormancy-overrides[%d]: negative window %s", i, override.Window))
+		}
+	}
+	if cfg.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("rate-limit: negative rate %g", cfg.RateLimit))
+	}
+	for i, override := range cfg.RateLimitOverrides {
+		if override.Control == "" && override.Type == "" {
+			errs = append(errs, fmt.Errorf("rate-limit-overrides[%d]: needs a control or a type", i))
+		}
+		if override.Rate < 0 {
+			errs = append(errs, fmt.Errorf("rate-limit-overrides[%d]: negative rate %g", i, override.Rate))
+		}
+	}
+	for i, names := range cfg.ArrayStateNames {
+		if names.Type == "" || names.State == "" {
+			errs = append(errs, fmt.Errorf("array-state-names[%d]: needs a type and a state", i))
+		}
+	}
+	for i, mapping := range cfg.ValueMappings {
+		if mapping.State == "" {
+			errs = append(errs, fmt.Errorf("value-mappings[%d]: needs a state", i))
+		}
+		if len(mapping.Values) == 0 {
+			errs = append(errs, fmt.Errorf("value-mappings[%d]: has no values", i))
+		}
+	}
+	for _, name := range cfg.Metrics.Labels {
+		if !contains(labelNames, name) {
+			errs = append(errs, fmt.Errorf("metrics.labels: unknown label %q", name))
+		}
+	}
+	for i, rule := range cfg.NormalizeLabels {
+		for _, name := range rule.Labels {
+			if !contains(labelNames, name) {
+				errs = append(errs, fmt.Errorf("normalize-labels[%d]: unknown label %q", i, name))
+			}
+		}
+	}
+	for i, transform := range cfg.Transforms {
+		if transform.Control == "" && transform.Type == "" {
+			errs = append(errs, fmt.Errorf("transforms[%d]: needs a control or a type", i))
+		}
+		if transform.Min != nil && transform.Max != nil && *transform.Min > *transform.Max {
+			errs = append(errs, fmt.Errorf("transforms[%d]: min %g is above max %g", i, *transform.Min, *transform.Max))
+		}
+	}
+	for i, override := range cfg.MetricTypes {
+		if override.Control == "" && override.Type == "" {
+			errs = append(errs, fmt.Errorf("metric-types[%d]: needs a control or a type", i))
+		}
+		switch override.As {
+		case metricTypeGauge, metricTypeCounter, metricTypeStateSet:
+		default:
+			errs = append(errs, fmt.Errorf("metric-types[%d]: unknown type %q, use gauge, counter or stateset", i, override.As))
+		}
+	}
+	for i, selected := range cfg.ValueHistogramStates {
+		if selected.Control == "" && selected.Type == "" {
+			errs = append(errs, fmt.Errorf("value-histogram-states[%d]: needs a control or a type", i))
+		}
+	}
+	if cfg.Clock.Interval > 0 {
+		if _, err := time.LoadLocation(cfg.Clock.Timezone); err != nil {
+			errs = append(errs, fmt.Errorf("clock.timezone: %v", err))
+		}
+	}
+	for i, color := range cfg.ColorStates {
+		if color.Control == "" && color.Type == "" {
+			errs = append(errs, fmt.Errorf("color-states[%d]: needs a control or a type", i))
+		}
+		switch color.Encoding {
+		case "", colorRGB, colorLumitech, colorAuto:
+		default:
+			errs = append(errs, fmt.Errorf("color-states[%d]: unknown encoding %q, use rgb, lumitech or auto", i, color.Encoding))
+		}
+	}
+	for i, poll := range cfg.Poll {
+		if poll.Control == "" {
+			errs = append(errs, fmt.Errorf("poll[%d]: needs a control", i))
+		}
+		if poll.Interval <= 0 {
+			errs = append(errs, fmt.Errorf("poll[%d]: needs a positive interval", i))
+		}
+	}
+	if cfg.TokenPermission != 0 && cfg.TokenPermission != loxonews.PermissionWeb && cfg.TokenPermission != loxonews.PermissionApp {
+		errs = append(errs, fmt.Errorf("token-permission must be 2 (web) or 4 (app)"))
+	}
+	if cfg.PruneInterval < 0 {
+		errs = append(errs, fmt.Errorf("prune-interval: negative interval %s", cfg.PruneInterval))
+	}
+	if cfg.MQTT.QoS < 0 || cfg.MQTT.QoS > 2 {
+		errs = append(errs, fmt.Errorf("mqtt.qos must be 0, 1 or 2"))
+	}
+	if cfg.Influx.URL != "" && (cfg.Influx.Bucket == "" || cfg.Influx.FlushInterval <= 0) {
+		errs = append(errs, fmt.Errorf("influx needs a bucket and a positive flush interval"))
+	}
+	if cfg.Loki.URL != "" && cfg.Loki.FlushInterval <= 0 {
+		errs = append(errs, fmt.Errorf("loki.flush-interval must be positive"))
+	}
+	if cfg.Graphite.Address != "" && cfg.Graphite.FlushInterval <= 0 {
+		errs = append(errs, fmt.Errorf("graphite.flush-interval must be positive"))
+	}
+	if cfg.RemoteWrite.URL != "" && cfg.RemoteWrite.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("remote-write.interval must be positive"))
+	}
+	if cfg.OTLP.Endpoint != "" && cfg.OTLP.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("otlp.interval must be positive"))
+	}
+	return errs
+}
@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/XciD/loxone-ws/events"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// weatherLabels are the labels of the forecast, horizon_hours counts the
+// hours from the first entry of the weather table
+var weatherLabels = []string{"miniserver", "horizon_hours"}
+
+// weatherGauge is a value of the forecast of an hour
+type weatherGauge struct {
+	name  string
+	vec   *prometheus.GaugeVec
+	value func(events.WeatherEntry) float64
+}
+
+func newWeatherGauge(name string, help string, value func(events.WeatherEntry) float64) weatherGauge {
+	return weatherGauge{
+		name:  name,
+		vec:   prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, weatherLabels),
+		value: value,
+	}
+}
+
+var (
+	weatherLastUpdate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_weather_last_update_timestamp_seconds",
+			Help: "Unix timestamp of the last update of the weather table",
+		},
+		[]string{"miniserver"},
+	)
+	weatherForecastTime = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loxone_weather_forecast_timestamp_seconds",
+			Help: "Unix timestamp of the hour of the forecast",
+		},
+		weatherLabels,
+	)
+	weatherGauges = []weatherGauge{
+		newWeatherGauge("loxone_weather_type", "Weather type of the forecast, the weatherTypeTexts of the structure file name them",
+			func(e events.WeatherEntry) float64 { return float64(e.WeatherType) }),
+		newWeatherGauge("loxone_weather_wind_direction_degrees", "Forecast wind direction",
+			func(e events.WeatherEntry) float64 { return float64(e.WindDirection) }),
+		newWeatherGauge("loxone_weather_solar_radiation_watts_per_square_meter", "Forecast solar radiation",
+			func(e events.WeatherEntry) float64 { return float64(e.SolarRadiation) }),
+		newWeatherGauge("loxone_weather_relative_humidity_percent", "Forecast relative humidity",
+			func(e events.WeatherEntry) float64 { return float64(e.RelativeHumidity) }),
+		newWeatherGauge("loxone_weather_temperature_celsius", "Forecast temperature",
+			func(e events.WeatherEntry) float64 { return e.Temperature }),
+		newWeatherGauge("loxone_weather_perceived_temperature_celsius", "Forecast perceived temperature",
+			func(e events.WeatherEntry) float64 { return e.PerceivedTemperature }),
+		newWeatherGauge("loxone_weather_dew_point_celsius", "Forecast dew point",
+			func(e events.WeatherEntry) float64 { return e.DewPoint }),
+		newWeatherGauge("loxone_weather_precipitation_millimeters", "Forecast precipitation of the hour",
+			func(e events.WeatherEntry) float64 { return e.Precipitation }),
+		newWeatherGauge("loxone_weather_wind_speed_kilometers_per_hour", "Forecast wind speed",
+			func(e events.WeatherEntry) float64 { return e.WindSpeed }),
+		newWeatherGauge("loxone_weather_pressure_hectopascals", "Forecast barometric pressure",
+			func(e events.WeatherEntry) float64 { return e.BarometricPressure }),
+	}
+)
+
+// registerWeatherMetrics registers the forecast metrics, they stay empty
+// without a weather service
+func registerWeatherMetrics() {
+	prometheus.MustRegister(weatherLastUpdate)
+	prometheus.MustRegister(weatherForecastTime)
+	for _, gauge := range weatherGauges {
+		prometheus.MustRegister(gauge.vec)
+	}
+}
+
+// updateWeather replaces the forecast of a Miniserver with a new weather
+// table, its times are in the time zone of the Miniserver clock
+func updateWeather(miniserver string, weather *events.Weather, location *time.Location) {
+	forgetWeather(miniserver)
+	weatherLastUpdate.WithLabelValues(miniserver).Set(float64(events.Time(weather.LastUpdate, location).Unix()))
+	if len(weather.Entries) == 0 {
+		return
+	}
+
+	first := weather.Entries[0].Timestamp
+	for _, entry := range weather.Entries {
+		horizon := strconv.FormatInt((entry.Timestamp-first)/3600, 10)
+		weatherForecastTime.WithLabelValues(miniserver, horizon).Set(float64(events.Time(entry.Timestamp, location).Unix()))
+		for _, gauge := range weatherGauges {
+			gauge.vec.WithLabelValues(miniserver, horizon).Set(gauge.value(entry))
+		}
+	}
+}
+
+// updateWeather exports a weather table received from the Miniserver
+func (s *session) updateWeather(weather *events.Weather) {
+	location, err := time.LoadLocation(s.cfg.Clock.Timezone)
+	if err != nil {
+		s.log.Warnf("Invalid clock.timezone, taking the local time zone for the weather: %v", err)
+		location = time.Local
+	}
+	updateWeather(s.miniserver.Name, weather, location)
+	s.log.Debugf("Weather table with %d entries received", len(weather.Entries))
+}
+
+// forgetWeather drops the forecast of a Miniserver
+func forgetWeather(miniserver string) {
+	weatherLastUpdate.DeleteLabelValues(miniserver)
+	weatherForecastTime.DeletePartialMatch(prometheus.Labels{"miniserver": miniserver})
+	for _, gauge := range weatherGauges {
+		gauge.vec.DeletePartialMatch(prometheus.Labels{"miniserver": miniserver})
+	}
+}
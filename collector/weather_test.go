@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/XciD/loxone-ws/events"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestUpdateWeather(t *testing.T) {
+	weather := &events.Weather{
+		LastUpdate: 3600,
+		Entries: []events.WeatherEntry{
+			{Timestamp: 3600, Temperature: 21.5},
+			{Timestamp: 7200, Temperature: 20},
+		},
+	}
+	updateWeather("weather", weather, time.UTC)
+	updateWeather("weather", &events.Weather{LastUpdate: 7200, Entries: weather.Entries[1:]}, time.UTC)
+
+	var temperature *prometheus.GaugeVec
+	for _, gauge := range weatherGauges {
+		if gauge.name == "loxone_weather_temperature_celsius" {
+			temperature = gauge.vec
+		}
+	}
+	if got := metricValue(t, temperature.WithLabelValues("weather", "0")); got != 20 {
+		t.Errorf("temperature of the first hour is %v, want the one of the new table", got)
+	}
+	if weatherForecastTime.DeleteLabelValues("weather", "1") {
+		t.Errorf("hour of the previous table is still exported")
+	}
+	want := float64(time.Date(2009, 1, 1, 2, 0, 0, 0, time.UTC).Unix())
+	if got := metricValue(t, weatherForecastTime.WithLabelValues("weather", "0")); got != want {
+		t.Errorf("forecast timestamp is %v, want %v", got, want)
+	}
+
+	forgetWeather("weather")
+	if temperature.DeleteLabelValues("weather", "0") {
+		t.Errorf("forecast of a forgotten Miniserver is still exported")
+	}
+}
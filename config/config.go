@@ -2,12 +2,28 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
 )
 
-const envPrefix string = "LOXPROM"
+const (
+	envPrefix       string = "LOXPROM"
+	loxoneEnvPrefix string = "LOXONE"
+)
+
+// metricPrefixRegex matches the valid metric name prefixes
+var metricPrefixRegex = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// MinLabelLength is the shortest --max-label-length, truncated values end
+// with an ellipsis and a 6 character hash and keep at least one rune
+const MinLabelLength = 8
 
 // ReadConfigErr is returned if something goes wrong while reading the config
 // We use this error to return a meaningful string
@@ -20,11 +36,515 @@ func (e *ReadConfigErr) Error() string {
 	return e.err
 }
 
+// WebConfig holds the settings of the HTTP server
+type WebConfig struct {
+	// ListenAddress is the address the HTTP server listens on
+	ListenAddress string `mapstructure:"listen-address"`
+	// Config is the exporter-toolkit web config with TLS and basic auth settings
+	Config struct {
+		File string
+	}
+	// EnableLifecycle serves /-/reload and /-/loglevel
+	EnableLifecycle bool `mapstructure:"enable-lifecycle"`
+	// EnablePprof serves the Go profiler under /debug/pprof
+	EnablePprof bool `mapstructure:"enable-pprof"`
+	// DisableExporterMetrics leaves the go_*, process_* and promhttp_* metrics out
+	DisableExporterMetrics bool `mapstructure:"disable-exporter-metrics"`
+}
+
+// LogConfig holds the level and format of the logs
+type LogConfig struct {
+	Level  string
+	Format string
+	// EventRate limits the logged events per control and minute, 0 logs all
+	EventRate int `mapstructure:"event-rate"`
+}
+
+// MetricsConfig holds the naming of the exported metrics
+type MetricsConfig struct {
+	// Prefix replaces the loxone namespace of all metrics
+	Prefix string
+	// Labels are the labels of the per state series, all by default
+	Labels []string
+	// EventTimestamps exposes the values of states with the time of their last event
+	EventTimestamps bool `mapstructure:"event-timestamps"`
+}
+
+// MQTTConfig holds the broker every state update is published to
+type MQTTConfig struct {
+	// Broker is the URL of the broker, e.g. tcp://localhost:1883, empty disables MQTT
+	Broker      string
+	User        string
+	Password    string
+	ClientID    string `mapstructure:"client-id"`
+	QoS         int    `mapstructure:"qos"`
+	Retain      bool
+	TopicPrefix string `mapstructure:"topic-prefix"`
+}
+
+// InfluxConfig holds the InfluxDB v2 bucket every state update is written to
+type InfluxConfig struct {
+	// URL of InfluxDB, e.g. http://localhost:8086, empty disables it
+	URL           string
+	Org           string
+	Bucket        string
+	Token         string
+	Measurement   string
+	BatchSize     int           `mapstructure:"batch-size"`
+	FlushInterval time.Duration `mapstructure:"flush-interval"`
+}
+
+// LokiConfig holds the Loki the texts of text states are pushed to
+type LokiConfig struct {
+	// URL of Loki, e.g. http://localhost:3100, empty disables it
+	URL string
+	// TenantID is sent as X-Scope-OrgID to a multi-tenant Loki
+	TenantID      string `mapstructure:"tenant-id"`
+	User          string
+	Password      string
+	BatchSize     int           `mapstructure:"batch-size"`
+	FlushInterval time.Duration `mapstructure:"flush-interval"`
+}
+
+// GraphiteConfig holds the Carbon server every state update is sent to
+type GraphiteConfig struct {
+	// Address of the plaintext protocol, e.g. localhost:2003, empty disables Graphite
+	Address       string
+	Prefix        string
+	FlushInterval time.Duration `mapstructure:"flush-interval"`
+}
+
+// RemoteWriteConfig holds the remote_write endpoint the metrics are pushed to
+type RemoteWriteConfig struct {
+	// URL of the endpoint, e.g. https://prometheus.example.com/api/v1/write, empty disables pushing
+	URL         string
+	Interval    time.Duration
+	Timeout     time.Duration
+	User        string
+	Password    string
+	BearerToken string `mapstructure:"bearer-token"`
+}
+
+// OTLPConfig holds the OpenTelemetry Collector the metrics are pushed to
+type OTLPConfig struct {
+	// Endpoint of OTLP over HTTP, e.g. http://localhost:4318, empty disables pushing
+	Endpoint string
+	// Headers are sent with every push, as name=value
+	Headers  []string
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// WebhookConfig calls a URL when a state update starts matching the filter
+type WebhookConfig struct {
+	URL string
+	// Method is POST by default
+	Method string
+	// Headers are sent with every call, as name=value
+	Headers []string
+	// Filter is an expression on the labels and the value, e.g. type == "SmokeAlarm" && value > 0
+	Filter string
+	// Template is a text/template of the body, a JSON object of the labels, value and time by default
+	Template string
+}
+
+// ConnectionHookConfig calls a URL or runs a command when a Miniserver
+// connects, disconnects or refuses the login
+type ConnectionHookConfig struct {
+	// Events are connect, disconnect and auth-failure, all of them by default
+	Events []string
+	URL    string
+	// Method is POST by default
+	Method string
+	// Headers are sent with every call, as name=value
+	Headers []string
+	// Command is run with the payload on stdin, the program and its arguments
+	Command []string
+	// Template is a text/template of the payload, a JSON object of the event by default
+	Template string
+}
+
+// BackfillConfig holds the settings of the backfill subcommand
+type BackfillConfig struct {
+	// Controls are the names or UUIDs of the controls to backfill, all by default
+	Controls []string
+	// From and To are the first and last months to backfill, as 2006-01
+	From string
+	To   string
+	// Timezone is the time zone of the Miniserver
+	Timezone string
+}
+
+// ClockConfig reads the clock of the Miniserver for loxone_miniserver_clock_offset_seconds
+type ClockConfig struct {
+	// Interval is how often the clock is read, 0 disables it
+	Interval time.Duration
+	// Timezone is the time zone of the Miniserver, its clock is in local time
+	Timezone string
+}
+
+// RulesConfig holds the settings of the gen-rules subcommand
+type RulesConfig struct {
+	// Job is the job label of the exporter in Prometheus
+	Job string
+	// StaleAfter is how long a sensor may go without a change
+	StaleAfter time.Duration `mapstructure:"stale-after"`
+}
+
+// MiniserverConfig holds the connection settings of one Miniserver
+type MiniserverConfig struct {
+	// Name is the value of the miniserver label, defaults to the host
+	Name string
+	Host string
+	// Serial is resolved to the host with the Loxone Cloud DNS
+	Serial   string
+	User     string
+	Password string
+	// VisuPassword reads the secured details of controls
+	VisuPassword string `mapstructure:"visu-password"`
+}
+
+// ModuleConfig holds the credentials /probe uses for its targets
+type ModuleConfig struct {
+	User     string
+	Password string
+}
+
+// ProbeConfig restricts the /probe targets
+type ProbeConfig struct {
+	// Targets are the hosts /probe may connect to besides the configured Miniservers
+	Targets []string
+	// TargetExpiry closes the connection to targets not scraped for that long, 0 keeps them open
+	TargetExpiry time.Duration `mapstructure:"target-expiry"`
+}
+
+// FilterConfig holds a regex per control field
+type FilterConfig struct {
+	Control string
+	Room    string
+	Cat     string
+	Type    string
+	UUID    string
+}
+
+// RelabelConfig is a relabeling rule for the labels of every state,
+// like a Prometheus relabel_config
+type RelabelConfig struct {
+	SourceLabels []string `mapstructure:"source_labels"`
+	Separator    string
+	Regex        string
+	TargetLabel  string `mapstructure:"target_label"`
+	Replacement  string
+	Action       string
+}
+
+// DebounceConfig overrides the debounce interval of a control or of a control type
+type DebounceConfig struct {
+	Control  string
+	Type     string
+	Interval time.Duration
+}
+
+// DormancyConfig overrides the dormancy window of a control, by name, or of
+// a control type, 0 keeps its series
+type DormancyConfig struct {
+	Control string
+	Type    string
+	Window  time.Duration
+}
+
+// RateLimitConfig overrides the updates per second of the states of a
+// control, by name, or of a control type, 0 doesn't limit them
+type RateLimitConfig struct {
+	Control string
+	Type    string
+	Rate    float64
+}
+
+// PollConfig reads the value of a control, by name or UUID, every interval
+// and updates its state, "value" by default
+type PollConfig struct {
+	Control  string
+	State    string
+	Interval time.Duration
+}
+
+// ArrayStateNames name the elements of an array state of a control type
+type ArrayStateNames struct {
+	Type  string
+	State string
+	Names []string
+}
+
+// ValueMapping names the values of a state, for loxone_state_info. Control
+// and type are optional.
+type ValueMapping struct {
+	Control string
+	Type    string
+	State   string
+	Values  []ValueName
+}
+
+// Transform rewrites the values of a state before they are exported: inverted
+// (1 - value), multiplied, added to and clamped, in this order. Control, type
+// and state are optional.
+type Transform struct {
+	Control  string
+	Type     string
+	State    string
+	Invert   bool
+	Multiply *float64
+	Add      float64
+	Min      *float64
+	Max      *float64
+}
+
+// MetricType overrides how a state is exported: gauge, counter or stateset.
+// Control, type and state are optional.
+type MetricType struct {
+	Control string
+	Type    string
+	State   string
+	As      string
+}
+
+// HistogramState selects states recorded in loxone_value_histogram. Control,
+// type and state are optional.
+type HistogramState struct {
+	Control string
+	Type    string
+	State   string
+}
+
+// ColorState unpacks the colors of a state into channels, encoded as rgb,
+// lumitech or auto, the default. Control, type and state are optional.
+type ColorState struct {
+	Control  string
+	Type     string
+	State    string
+	Encoding string
+}
+
+// NamesConfig overrides the names of controls and rooms by UUID
+type NamesConfig struct {
+	Controls map[string]string
+	Rooms    map[string]string
+}
+
+// NormalizeConfig normalizes the values of labels: they are trimmed, their
+// whitespace collapsed and invalid UTF-8 replaced, then optionally
+// transliterated to ASCII and lowercased
+type NormalizeConfig struct {
+	Labels        []string
+	Transliterate bool
+	Lowercase     bool
+}
+
+// ValueName is the name of a value
+type ValueName struct {
+	Value float64
+	Name  string
+}
+
+// TLSConfig holds the TLS settings of wss:// Miniservers
+type TLSConfig struct {
+	CAFile             string `mapstructure:"ca-file"`
+	CertFile           string `mapstructure:"cert-file"`
+	KeyFile            string `mapstructure:"key-file"`
+	ServerName         string `mapstructure:"server-name"`
+	InsecureSkipVerify bool   `mapstructure:"insecure-skip-verify"`
+}
+
 // Config holds our config values
 type Config struct {
 	Host     string
+	Serial   string
 	User     string
 	Password string
+	// VisuPassword is the visualization password of the user, for secured controls
+	VisuPassword string `mapstructure:"visu-password"`
+	// The files replace User, Password and VisuPassword with their content, e.g. for Docker secrets
+	UserFile         string `mapstructure:"user-file"`
+	PasswordFile     string `mapstructure:"password-file"`
+	VisuPasswordFile string `mapstructure:"visu-password-file"`
+	Web              WebConfig
+	Metrics          MetricsConfig
+	Log              LogConfig
+	MQTT             MQTTConfig     `mapstructure:"mqtt"`
+	Influx           InfluxConfig   `mapstructure:"influx"`
+	Loki             LokiConfig     `mapstructure:"loki"`
+	Graphite         GraphiteConfig `mapstructure:"graphite"`
+	// RemoteWrite pushes the metrics for exporters Prometheus can't scrape
+	RemoteWrite RemoteWriteConfig `mapstructure:"remote-write"`
+	OTLP        OTLPConfig        `mapstructure:"otlp"`
+
+	// Version prints the version instead of running the exporter
+	Version bool `mapstructure:"version"`
+	// Args are the arguments after the flags, a subcommand and its arguments
+	Args []string `mapstructure:"-"`
+	// Output is the format of the subcommands, table or json
+	Output string `mapstructure:"output"`
+	// DryRun maps the structure files, prints a summary and exits
+	DryRun bool `mapstructure:"dry-run"`
+	// Rules configures the alerting rules of gen-rules
+	Rules RulesConfig `mapstructure:"rules"`
+	// Backfill configures the backfill subcommand
+	Backfill BackfillConfig `mapstructure:"backfill"`
+
+	// Miniservers configures several Miniservers instead of Host, User and Password
+	Miniservers []MiniserverConfig `mapstructure:"miniservers"`
+	// Include and Exclude decide which controls are exported
+	Include FilterConfig `mapstructure:"include"`
+	Exclude FilterConfig `mapstructure:"exclude"`
+
+	// TLS configures the connections to wss:// Miniservers
+	TLS TLSConfig `mapstructure:"tls"`
+	// AllowPlaintext allows connecting to Miniservers without TLS
+	AllowPlaintext bool `mapstructure:"allow-plaintext"`
+
+	// Debounce is how long a state must be stable before a change is counted, 0 disables it
+	Debounce time.Duration `mapstructure:"debounce"`
+	// RateLimit is how many events per second a state takes, the last one of a burst wins, 0 disables it
+	RateLimit float64 `mapstructure:"rate-limit"`
+	// RateLimitOverrides set the rate limit by control name or type
+	RateLimitOverrides []RateLimitConfig `mapstructure:"rate-limit-overrides"`
+	// DebounceOverrides set the debounce interval by control name or type
+	DebounceOverrides []DebounceConfig `mapstructure:"debounce-overrides"`
+
+	// Poll reads controls in addition to their events
+	Poll []PollConfig `mapstructure:"poll"`
+	// Webhooks are called when state updates match their filter
+	Webhooks []WebhookConfig `mapstructure:"webhooks"`
+	// ConnectionHooks are called when Miniservers connect, disconnect or refuse the login
+	ConnectionHooks []ConnectionHookConfig `mapstructure:"connection-hooks"`
+
+	// ValueMappings name the values of states
+	ValueMappings []ValueMapping `mapstructure:"value-mappings"`
+	// Transforms rewrite the values of states
+	Transforms []Transform `mapstructure:"transforms"`
+	// MetricTypes override how states are exported
+	MetricTypes []MetricType `mapstructure:"metric-types"`
+	// ColorStates unpack packed color values into loxone_color_channel
+	ColorStates []ColorState `mapstructure:"color-states"`
+
+	// Names override the control and room labels by UUID
+	Names NamesConfig `mapstructure:"names"`
+	// NormalizeLabels clean up label values before the relabel rules
+	NormalizeLabels []NormalizeConfig `mapstructure:"normalize-labels"`
+
+	// Relabel rules are applied in order to the labels of every state
+	Relabel []RelabelConfig `mapstructure:"relabel"`
+
+	// Modules are the credentials /probe can use, by module name
+	Modules map[string]ModuleConfig `mapstructure:"modules"`
+	Probe   ProbeConfig             `mapstructure:"probe"`
+
+	// LastChangeTimestamp enables the loxone_last_change_timestamp_seconds gauge
+	LastChangeTimestamp bool `mapstructure:"last-change-timestamp"`
+	// StateTimestamps enables the loxone_state_last_change_timestamp_seconds gauge
+	StateTimestamps bool `mapstructure:"state-timestamps"`
+	// DormancyWindow hides series without events for longer than the window, 0 disables it
+	DormancyWindow time.Duration `mapstructure:"dormancy-window"`
+	// DormancyOverrides set the dormancy window by control name or type
+	DormancyOverrides []DormancyConfig `mapstructure:"dormancy-overrides"`
+	// KeepaliveInterval is how often the Miniserver is probed, a probe not answered
+	// within KeepaliveTimeout ends the connection
+	KeepaliveInterval time.Duration `mapstructure:"keepalive-interval"`
+	KeepaliveTimeout  time.Duration `mapstructure:"keepalive-timeout"`
+	// DialTimeout limits the TCP connect to the Miniserver
+	DialTimeout time.Duration `mapstructure:"dial-timeout"`
+	// LocalAddr is the local IP the connections to the Miniserver are bound to
+	LocalAddr string `mapstructure:"local-addr"`
+	// ProxyURL is the SOCKS5 or HTTP proxy to the Miniserver, HTTP_PROXY and HTTPS_PROXY by default
+	ProxyURL string `mapstructure:"proxy-url"`
+	// TokenPermission is the permission of the token, 2 for web or 4 for app
+	TokenPermission int `mapstructure:"token-permission"`
+	// EncryptCommands encrypts the commands sent after the login, for Miniservers without unencrypted connections
+	EncryptCommands bool `mapstructure:"encrypt-commands"`
+	// ReportFile is where the JSON startup report is written to, if set
+	ReportFile string `mapstructure:"report-file"`
+	// ValueHistograms records every received value in loxone_value_histogram
+	ValueHistograms bool `mapstructure:"value-histograms"`
+	// SystemStatsInterval is how often the system statistics of the Miniserver are read
+	SystemStatsInterval time.Duration `mapstructure:"system-stats-interval"`
+	// Clock compares the clock of the Miniserver with the one of the exporter
+	Clock ClockConfig
+	// StateFile persists the change counters every StateSaveInterval
+	StateFile         string        `mapstructure:"state-file"`
+	StateSaveInterval time.Duration `mapstructure:"state-save-interval"`
+	// Dedup ignores events repeating the value of the state, within DedupEpsilon
+	Dedup        bool
+	DedupEpsilon float64 `mapstructure:"dedup-epsilon"`
+	// EventWorkers update the states from queues of EventQueueSize events
+	EventWorkers   int `mapstructure:"event-workers"`
+	EventQueueSize int `mapstructure:"event-queue-size"`
+	// EventBuffer is how many of the last events /api/v1/events keeps, 0 disables it
+	EventBuffer int `mapstructure:"event-buffer"`
+	// EventHistograms records loxone_event_interval_seconds and loxone_event_processing_duration_seconds
+	EventHistograms bool `mapstructure:"event-histograms"`
+	// NativeHistograms switches the histograms to native histograms
+	NativeHistograms bool `mapstructure:"native-histograms"`
+	// ValueHistogramBuckets are the classic buckets of the value histograms
+	ValueHistogramBuckets []float64 `mapstructure:"value-histogram-buckets"`
+	// ValueHistogramStates limit the value histograms to these states, and enable them
+	ValueHistogramStates []HistogramState `mapstructure:"value-histogram-states"`
+	// UpDownGrace is how long the connection must be down before loxone_up drops to 0
+	UpDownGrace time.Duration `mapstructure:"up-down-grace"`
+	// FailAfter exits once a Miniserver stayed disconnected this long, 0 retries forever
+	FailAfter time.Duration `mapstructure:"fail-after"`
+	// ArrayChildLabels moves the index of array state children into an element label
+	ArrayChildLabels bool `mapstructure:"array-child-labels"`
+	// SubControls exports the states of subcontrols with a subcontrol label
+	SubControls bool `mapstructure:"subcontrols"`
+	// UUIDLabels adds the control_uuid and room_uuid labels
+	UUIDLabels bool `mapstructure:"uuid-labels"`
+	// ArrayStateNames are used instead of the index of array state children
+	ArrayStateNames []ArrayStateNames `mapstructure:"array-state-names"`
+	// AdminUser and AdminPassword protect the admin endpoints with basic auth
+	AdminUser     string `mapstructure:"admin-user"`
+	AdminPassword string `mapstructure:"admin-password"`
+	// WriteUser and WritePassword enable the write API, which sends commands to controls
+	WriteUser     string `mapstructure:"write-user"`
+	WritePassword string `mapstructure:"write-password"`
+	// WriteAllowInsecure enables the write API without TLS on the web server
+	WriteAllowInsecure bool `mapstructure:"write-allow-insecure"`
+	// FloorRegex extracts the floor label from the room name with its first capture group
+	FloorRegex string `mapstructure:"floor-regex"`
+	// FloorPrefixes maps room name prefixes to floor labels
+	FloorPrefixes map[string]string `mapstructure:"floor-prefixes"`
+	// FloorFallback is the floor label of rooms matching no floor rule
+	FloorFallback string `mapstructure:"floor-fallback"`
+	// PruneInterval is how often series of unmapped states are deleted, 0 disables it
+	PruneInterval time.Duration `mapstructure:"prune-interval"`
+	// SecurityMetrics exports the state of alarm and smoke alarm controls as dedicated metrics
+	SecurityMetrics bool `mapstructure:"security-metrics"`
+	// ClimateMetrics exports the temperatures and modes of room controllers by room
+	ClimateMetrics bool `mapstructure:"climate-metrics"`
+	// RoomActivityTypes are the control types whose changes count as activity of their room
+	RoomActivityTypes []string `mapstructure:"room-activity-types"`
+	// AccessMetrics counts the bell presses of intercoms and the accesses of NFC Code Touch controls
+	AccessMetrics bool `mapstructure:"access-metrics"`
+	// MaxLabelLength truncates longer label values, 0 means no limit
+	MaxLabelLength int `mapstructure:"max-label-length"`
+	// ControlInfo exports loxone_control_info with one series per control
+	ControlInfo bool `mapstructure:"control-info"`
+	// ControlMetadata exports loxone_control_metadata_info with the rating, format and statistics of every control
+	ControlMetadata bool `mapstructure:"control-metadata"`
+	// ReconnectBackoff is the first delay before reconnecting, doubled up to ReconnectMaxBackoff
+	ReconnectBackoff    time.Duration `mapstructure:"reconnect-backoff"`
+	ReconnectMaxBackoff time.Duration `mapstructure:"reconnect-max-backoff"`
+	// StructureCheckInterval is how often the structure file is checked for changes, 0 disables it
+	StructureCheckInterval time.Duration `mapstructure:"structure-check-interval"`
+	// Record writes the structure file and every event to this file, for --replay
+	Record string `mapstructure:"record"`
+	// Replay feeds a recording into the exporter instead of connecting to Miniservers
+	Replay string `mapstructure:"replay"`
+	// ReplaySpeed divides the delays between the replayed events, 0 replays without delay
+	ReplaySpeed float64 `mapstructure:"replay-speed"`
+	// TypedMetrics exports states with a known unit in their format as loxone_<quantity>_<unit>
+	TypedMetrics bool `mapstructure:"typed-metrics"`
+	// Aggregates exports the power by room and category and the lights on by room
+	Aggregates bool `mapstructure:"aggregates"`
 }
 
 // NewConfig reads the config into a new Config object
@@ -33,15 +553,156 @@ func NewConfig() (*Config, error) {
 	cfg := new(Config)
 
 	// Flags
-	pflag.String("configFile", "", "Path and name of Config")
+	pflag.Bool("version", false, "Print the version and exit")
+	pflag.String("output", "table", "Output format of the subcommands: table or json")
+	pflag.Bool("dry-run", false, "Download and map the structure files, print a summary and exit")
+	pflag.String("rules.job", "loxone", "Job label of the exporter in the rules of gen-rules")
+	pflag.StringSlice("backfill.controls", nil, "Names or UUIDs of the controls backfill reads the statistics of, all by default")
+	pflag.String("backfill.from", "", "First month backfill reads, as 2006-01, the current month by default")
+	pflag.String("backfill.to", "", "Last month backfill reads, as 2006-01, the current month by default")
+	pflag.String("backfill.timezone", "Local", "Time zone of the Miniserver, its statistics are in local time")
+	pflag.Duration("rules.stale-after", 6*time.Hour, "How long a sensor may go without a change before gen-rules alerts")
+	pflag.String("config.file", "", "Path and name of Config (YAML or TOML)")
+	pflag.String("configFile", "", "Deprecated, use --config.file")
 	pflag.String("host", "", "URL of the Miniserver")
+	pflag.String("serial", "", "Serial number of the Miniserver, its address is resolved with the Loxone Cloud DNS")
 	pflag.String("user", "", "Username for Miniserver")
 	pflag.String("password", "", "Password for Miniserver")
+	pflag.String("visu-password", "", "Visualization password of the user, to read the secured details of controls")
+	pflag.String("user-file", "", "File holding the user for Miniserver, e.g. a Docker secret")
+	pflag.String("password-file", "", "File holding the password for Miniserver, e.g. a Docker secret")
+	pflag.String("visu-password-file", "", "File holding the visualization password of the user")
+	pflag.String("web.listen-address", ":8080", "Address to listen on for the metrics endpoint")
+	pflag.String("web.config.file", "", "Path to a web config file enabling TLS and basic auth, see exporter-toolkit")
+	pflag.Bool("web.enable-lifecycle", false, "Serve /-/reload and /-/loglevel, behind the admin credentials")
+	pflag.Bool("web.enable-pprof", false, "Serve the Go profiler under /debug/pprof, behind the admin credentials")
+	pflag.Bool("web.disable-exporter-metrics", false, "Leave the go_*, process_* and promhttp_* metrics of the exporter itself out of /metrics")
+	pflag.String("log.level", "info", "Log level: trace, debug, info, warn or error")
+	pflag.String("log.format", "text", "Log format: text or json")
+	pflag.Int("log.event-rate", 0, "Log at most this many events per control and minute at debug level, 0 logs all")
+	pflag.String("mqtt.broker", "", "URL of an MQTT broker to publish every state update to, e.g. tcp://localhost:1883")
+	pflag.String("mqtt.user", "", "Username for the MQTT broker")
+	pflag.String("mqtt.password", "", "Password for the MQTT broker")
+	pflag.String("mqtt.client-id", "loxone-exporter", "Client ID at the MQTT broker")
+	pflag.Int("mqtt.qos", 0, "QoS of the published messages: 0, 1 or 2")
+	pflag.Bool("mqtt.retain", false, "Publish retained messages")
+	pflag.String("mqtt.topic-prefix", "loxone", "First level of the topics, followed by room, control and state")
+	pflag.String("influx.url", "", "URL of an InfluxDB v2 to write every state update to, e.g. http://localhost:8086")
+	pflag.String("influx.org", "", "InfluxDB organization")
+	pflag.String("influx.bucket", "", "InfluxDB bucket")
+	pflag.String("influx.token", "", "InfluxDB API token")
+	pflag.String("influx.measurement", "loxone", "InfluxDB measurement of the state updates")
+	pflag.Int("influx.batch-size", 1000, "Number of state updates written to InfluxDB at once")
+	pflag.Duration("influx.flush-interval", 10*time.Second, "How often pending state updates are written to InfluxDB")
+	pflag.String("loki.url", "", "URL of a Loki to push the texts of text states to, e.g. http://localhost:3100")
+	pflag.String("loki.tenant-id", "", "Tenant of a multi-tenant Loki, sent as X-Scope-OrgID")
+	pflag.String("loki.user", "", "Username for basic auth at Loki")
+	pflag.String("loki.password", "", "Password for basic auth at Loki")
+	pflag.Int("loki.batch-size", 100, "Number of texts pushed to Loki at once")
+	pflag.Duration("loki.flush-interval", 10*time.Second, "How often pending texts are pushed to Loki")
+	pflag.String("graphite.address", "", "Address of a Carbon server to send every state update to with the plaintext protocol, e.g. localhost:2003")
+	pflag.String("graphite.prefix", "loxone", "First node of the Graphite metric paths")
+	pflag.Duration("graphite.flush-interval", 10*time.Second, "How often pending state updates are sent to Carbon")
+	pflag.String("remote-write.url", "", "URL of a remote_write endpoint to push the metrics to, e.g. for Grafana Cloud or Mimir")
+	pflag.Duration("remote-write.interval", time.Minute, "How often the metrics are pushed")
+	pflag.Duration("remote-write.timeout", 30*time.Second, "How long a push may take")
+	pflag.String("remote-write.user", "", "Username for basic auth at the remote_write endpoint")
+	pflag.String("remote-write.password", "", "Password for basic auth at the remote_write endpoint")
+	pflag.String("remote-write.bearer-token", "", "Bearer token for the remote_write endpoint, instead of basic auth")
+	pflag.String("otlp.endpoint", "", "OTLP/HTTP endpoint of an OpenTelemetry Collector to push the metrics to, e.g. http://localhost:4318")
+	pflag.StringSlice("otlp.headers", nil, "Headers sent to the OTLP endpoint, as name=value")
+	pflag.Duration("otlp.interval", time.Minute, "How often the metrics are pushed to the OTLP endpoint")
+	pflag.Duration("otlp.timeout", 30*time.Second, "How long a push to the OTLP endpoint may take")
+	pflag.String("metrics.prefix", "loxone", "Namespace of all exported metrics")
+	pflag.Bool("metrics.event-timestamps", false, "Expose the values of states with the timestamp of their last event and offer the OpenMetrics format")
+	pflag.StringSlice("metrics.labels", nil, "Labels of the per state series, the series left with the same labels are merged, all by default")
+	pflag.Duration("debounce", 500*time.Millisecond, "How long a state must be stable before a change is counted, 0 disables debouncing")
+	pflag.Float64("rate-limit", 0, "Events per second a state takes, the last value of a burst is applied later, 0 disables the limit")
+	pflag.Bool("last-change-timestamp", false, "Export the timestamp of the last counted change per series")
+	pflag.Bool("state-timestamps", false, "Export the timestamp of the last event per series, also for dormant series")
+	pflag.Duration("dormancy-window", 0, "Hide loxone_values series without events for longer than this window (0 disables)")
+	pflag.Duration("keepalive-interval", 30*time.Second, "How often to check the Miniserver still answers, which keeps the connection alive")
+	pflag.Duration("keepalive-timeout", 10*time.Second, "How long the Miniserver may take to answer before reconnecting")
+	pflag.Duration("dial-timeout", 30*time.Second, "Timeout of the TCP connect to the Miniserver")
+	pflag.String("local-addr", "", "Local IP address used to connect to the Miniserver")
+	pflag.Int("token-permission", 4, "Permission of the login token, 2 (web) or 4 (app), a web token expires sooner")
+	pflag.Bool("encrypt-commands", false, "Encrypt the commands sent to the Miniserver, needed when it doesn't allow unencrypted connections")
+	pflag.String("proxy-url", "", "Proxy to the Miniserver, socks5://, http:// or https://, HTTP_PROXY and HTTPS_PROXY by default")
+	pflag.String("report-file", "", "Write a JSON summary of the mapped controls to this file at startup")
+	pflag.Bool("value-histograms", false, "Record the distribution of received values per series")
+	pflag.Duration("system-stats-interval", 0, "How often to read the CPU load, heap and tasks of the Miniserver, 0 disables it")
+	pflag.Duration("clock.interval", 0, "How often to compare the clock of the Miniserver with the one of the exporter, 0 disables it")
+	pflag.String("clock.timezone", "Local", "Time zone of the Miniserver, its clock is in local time")
+	pflag.String("state-file", "", "Save the change counters to this file and restore them at startup, empty disables it")
+	pflag.Duration("state-save-interval", time.Minute, "How often the change counters are saved to the state file")
+	pflag.Bool("dedup", false, "Ignore events repeating the value of the state, they don't count as changes")
+	pflag.Float64("dedup-epsilon", 0, "Difference to the value of the state below which --dedup ignores an event")
+	pflag.Int("event-workers", 0, "Number of goroutines updating the states, 0 updates them while reading events")
+	pflag.Int("event-queue-size", 1000, "Number of events queued per event worker before events are dropped")
+	pflag.Int("event-buffer", 1000, "Number of the last events kept for /api/v1/events, 0 disables it")
+	pflag.Bool("event-histograms", false, "Record the intervals between events and their processing durations per control type")
+	pflag.Bool("native-histograms", false, "Use native histograms instead of classic buckets for the histograms")
+	pflag.Duration("up-down-grace", 0, "How long the connection must be down before loxone_up drops to 0")
+	pflag.Duration("fail-after", 0, "Exit with an error once a Miniserver stayed disconnected this long, 0 retries forever")
+	pflag.Bool("array-child-labels", false, "Label array state children with an element label instead of a state suffix")
+	pflag.Bool("subcontrols", false, "Export the states of subcontrols, with a subcontrol label")
+	pflag.Bool("uuid-labels", false, "Add control_uuid and room_uuid labels, series then survive renames")
+	pflag.StringSlice("probe.targets", nil, "Hosts /probe may connect to besides the configured Miniservers")
+	pflag.Duration("probe.target-expiry", 10*time.Minute, "Close the connection to /probe targets not scraped for that long, 0 keeps them open")
+	pflag.String("admin-user", "", "Username for the admin endpoints, enables basic auth")
+	pflag.String("admin-password", "", "Password for the admin endpoints")
+	pflag.String("write-user", "", "Username for the write API sending commands to controls, enables it")
+	pflag.String("write-password", "", "Password for the write API")
+	pflag.Bool("write-allow-insecure", false, "Enable the write API without TLS in --web.config.file, the credentials go over the wire in clear text")
+	pflag.String("floor-regex", "", "Regex extracting a floor label from the room name, e.g. ^([A-Z]+)_")
+	pflag.String("floor-fallback", "unknown", "Floor label of rooms matching no floor rule")
+	pflag.Duration("prune-interval", 10*time.Minute, "How often series of states no longer mapped are deleted, 0 disables pruning")
+	pflag.Bool("climate-metrics", false, "Export the temperatures, operating mode and open windows of room controllers by room")
+	pflag.StringSlice("room-activity-types", nil, "Control types whose changes count as activity of their room in loxone_room_activity_timestamp_seconds, e.g. PresenceDetector,Switch")
+	pflag.Bool("access-metrics", false, "Count the bell presses of intercoms and the access history entries of NFC Code Touch controls")
+	pflag.Bool("security-metrics", false, "Export the armed state, level and triggers of alarm and smoke alarm controls")
+	pflag.Int("max-label-length", 0, "Truncate label values longer than this, at least 8, 0 means no limit")
+	pflag.Bool("control-info", false, "Export loxone_control_info with one series per control")
+	pflag.Bool("control-metadata", false, "Export loxone_control_metadata_info with the default rating, format and statistics settings of every control")
+	pflag.Duration("reconnect-backoff", time.Second, "Initial delay before reconnecting to the Miniserver")
+	pflag.Duration("reconnect-max-backoff", 5*time.Minute, "Maximum delay before reconnecting to the Miniserver")
+	pflag.String("tls.ca-file", "", "CA bundle to verify wss:// Miniservers with, the system roots by default")
+	pflag.String("tls.cert-file", "", "Client certificate for wss:// Miniservers")
+	pflag.String("tls.key-file", "", "Key of the client certificate")
+	pflag.String("tls.server-name", "", "Server name to verify the certificate of wss:// Miniservers against")
+	pflag.Bool("tls.insecure-skip-verify", false, "Don't verify the certificate of wss:// Miniservers")
+	pflag.Bool("allow-plaintext", false, "Allow connecting to Miniservers without TLS, the token goes over the wire in clear text")
+	pflag.Duration("structure-check-interval", 5*time.Minute, "How often to check the structure file for changes and reload it, 0 disables it")
+	pflag.String("record", "", "Append the structure file and every event to this file, to replay them later")
+	pflag.String("replay", "", "Replay a recording instead of connecting to Miniservers")
+	pflag.Float64("replay-speed", 1, "Speed factor of the replay, 0 replays all events at once")
+	pflag.Bool("typed-metrics", false, "Export states with a known unit like loxone_temperature_celsius, next to loxone_values")
+	pflag.Bool("aggregates", false, "Export the power by room and category and the number of lights on by room")
+	for _, kind := range []string{"include", "exclude"} {
+		for _, field := range []string{"control", "room", "cat", "type", "uuid"} {
+			pflag.String(kind+"."+field, "", fmt.Sprintf("Regex on the %s, controls matching it are %sd", field, kind))
+		}
+	}
 	pflag.Parse()
 	viper.BindPFlags(pflag.CommandLine)
 
+	// Environment Variables, e.g. LOXPROM_WEB_LISTEN_ADDRESS for --web.listen-address
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.AutomaticEnv()
+	bindEnv("host", loxoneEnvPrefix+"_HOST")
+	bindEnv("user", loxoneEnvPrefix+"_USER")
+	bindEnv("password", loxoneEnvPrefix+"_PASSWORD")
+	bindEnv("user-file", loxoneEnvPrefix+"_USER_FILE")
+	bindEnv("password-file", loxoneEnvPrefix+"_PASSWORD_FILE")
+	bindEnv("visu-password-file", loxoneEnvPrefix+"_VISU_PASSWORD_FILE")
+
 	// Config file
-	if file := viper.GetString("configFile"); file != "" {
+	file := viper.GetString("config.file")
+	if file == "" {
+		file = viper.GetString("configFile")
+	}
+	if file != "" {
 		viper.SetConfigFile(file)
 	} else {
 		viper.SetConfigName("loxone-prometheus-exporter")
@@ -58,15 +719,197 @@ func NewConfig() (*Config, error) {
 		}
 	}
 
-	// Environment Variables
-	viper.SetEnvPrefix(envPrefix)
-	viper.BindEnv("Host")
-	viper.BindEnv("User")
-	viper.BindEnv("Password")
+	err = viper.Unmarshal(cfg)
+	if err != nil {
+		return nil, &ReadConfigErr{fmt.Sprintf("Unable to marshal config: %v", err)}
+	}
+	err = cfg.readSecrets()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.validate()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Args = pflag.Args()
+	return cfg, nil
+}
 
+// Reload reads the config file again into a new Config, flags and
+// environment variables still take precedence
+func Reload() (*Config, error) {
+	err := viper.ReadInConfig()
+	if err != nil {
+		switch err.(type) {
+		case viper.ConfigFileNotFoundError:
+		default:
+			return nil, &ReadConfigErr{fmt.Sprintf("Unable to read config file %s: %v", viper.GetViper().ConfigFileUsed(), err)}
+		}
+	}
+
+	cfg := new(Config)
 	err = viper.Unmarshal(cfg)
 	if err != nil {
 		return nil, &ReadConfigErr{fmt.Sprintf("Unable to marshal config: %v", err)}
 	}
+	err = cfg.readSecrets()
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.validate()
+	if err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
+
+// readSecrets replaces the credentials by the content of their files, if
+// configured, without the trailing newline
+func (c *Config) readSecrets() error {
+	for _, secret := range []struct {
+		file  string
+		value *string
+	}{
+		{c.UserFile, &c.User},
+		{c.PasswordFile, &c.Password},
+		{c.VisuPasswordFile, &c.VisuPassword},
+	} {
+		if secret.file == "" {
+			continue
+		}
+		content, err := ioutil.ReadFile(secret.file)
+		if err != nil {
+			return &ReadConfigErr{fmt.Sprintf("Unable to read secret: %v", err)}
+		}
+		*secret.value = strings.TrimRight(string(content), "\r\n")
+	}
+	return nil
+}
+
+// validate checks the settings a new config and a reloaded one must both pass
+func (c *Config) validate() error {
+	if !metricPrefixRegex.MatchString(c.Metrics.Prefix) {
+		return &ReadConfigErr{fmt.Sprintf("Invalid metrics prefix %q", c.Metrics.Prefix)}
+	}
+	if c.MaxLabelLength != 0 && c.MaxLabelLength < MinLabelLength {
+		return &ReadConfigErr{fmt.Sprintf("Invalid max label length %d, use 0 or at least %d", c.MaxLabelLength, MinLabelLength)}
+	}
+	return c.checkWriteAPI()
+}
+
+// checkWriteAPI refuses to enable the write API without a password, or
+// without TLS unless WriteAllowInsecure is set
+func (c *Config) checkWriteAPI() error {
+	if c.WriteUser == "" {
+		return nil
+	}
+	if c.WritePassword == "" {
+		return &ReadConfigErr{"The write API needs --write-password along with --write-user"}
+	}
+	if c.WriteAllowInsecure {
+		return nil
+	}
+	tls, err := webTLSEnabled(c.Web.Config.File)
+	if err != nil {
+		return &ReadConfigErr{fmt.Sprintf("Unable to read web config file %s: %v", c.Web.Config.File, err)}
+	}
+	if !tls {
+		return &ReadConfigErr{"The write API needs TLS in --web.config.file, or --write-allow-insecure"}
+	}
+	return nil
+}
+
+// webTLSEnabled tells whether the exporter-toolkit web config file sets a certificate
+func webTLSEnabled(file string) (bool, error) {
+	if file == "" {
+		return false, nil
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+	var web struct {
+		TLSServerConfig struct {
+			CertFile string `yaml:"cert_file"`
+		} `yaml:"tls_server_config"`
+	}
+	err = yaml.Unmarshal(content, &web)
+	if err != nil {
+		return false, err
+	}
+	return web.TLSServerConfig.CertFile != "", nil
+}
+
+// Load reads the given config file instead of the configured one, flags and
+// environment variables still take precedence
+func Load(file string) (*Config, error) {
+	viper.SetConfigFile(file)
+	return Reload()
+}
+
+// CheckKeys fails on keys of the config file that are no setting, e.g. typos
+func CheckKeys(file string) error {
+	strict := viper.New()
+	strict.SetConfigFile(file)
+	err := strict.ReadInConfig()
+	if err != nil {
+		return &ReadConfigErr{fmt.Sprintf("Unable to read config file %s: %v", file, err)}
+	}
+	err = strict.UnmarshalExact(new(Config))
+	if err != nil {
+		return &ReadConfigErr{fmt.Sprintf("Invalid config file %s: %v", file, err)}
+	}
+	return nil
+}
+
+// Module returns the credentials of a /probe module, the empty
+// module uses User and Password
+func (c *Config) Module(name string) (ModuleConfig, bool) {
+	if name == "" {
+		return ModuleConfig{User: c.User, Password: c.Password}, c.User != ""
+	}
+	module, ok := c.Modules[name]
+	return module, ok
+}
+
+// bindEnv lets the LOXONE_ variable of a key win over the LOXPROM_ one when it is set
+func bindEnv(key string, name string) {
+	if _, ok := os.LookupEnv(name); ok {
+		viper.BindEnv(key, name)
+	}
+}
+
+// MiniserverConfigs returns the configured Miniservers, that's Host, User and
+// Password unless a list of miniservers is configured. Without any host only
+// /probe targets are watched.
+func (c *Config) MiniserverConfigs() ([]MiniserverConfig, error) {
+	miniservers := c.Miniservers
+	if len(miniservers) == 0 && (c.Host != "" || c.Serial != "") {
+		miniservers = []MiniserverConfig{{Host: c.Host, Serial: c.Serial, User: c.User, Password: c.Password, VisuPassword: c.VisuPassword}}
+	}
+	if len(miniservers) == 0 && len(c.Probe.Targets) == 0 {
+		return nil, &ReadConfigErr{"No Miniserver configured, host, user and password are required"}
+	}
+
+	names := make(map[string]bool)
+	for i := range miniservers {
+		ms := &miniservers[i]
+		if (ms.Host == "" && ms.Serial == "") || ms.User == "" || ms.Password == "" {
+			return nil, &ReadConfigErr{fmt.Sprintf("Miniserver %d: host or serial, user and password are required", i+1)}
+		}
+		if ms.Host != "" && ms.Serial != "" {
+			return nil, &ReadConfigErr{fmt.Sprintf("Miniserver %d: host and serial can't be used together", i+1)}
+		}
+		if ms.Name == "" {
+			ms.Name = ms.Host + ms.Serial
+		}
+		if ms.VisuPassword == "" {
+			ms.VisuPassword = c.VisuPassword
+		}
+		if names[ms.Name] {
+			return nil, &ReadConfigErr{fmt.Sprintf("Miniserver name %s is used twice", ms.Name)}
+		}
+		names[ms.Name] = true
+	}
+	return miniservers, nil
+}
@@ -0,0 +1,14 @@
+package loxone
+
+import "strings"
+
+// IsAuthError tells whether the Miniserver refused the login, the user or
+// password is wrong or the user is locked out after too many attempts
+func IsAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	// loxone-ws only returns the code of the answer
+	message := err.Error()
+	return strings.Contains(message, "code: 401") || strings.Contains(message, "code: 403")
+}
@@ -0,0 +1,39 @@
+package loxone
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff computes exponentially growing delays with jitter
+type Backoff struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+// NewBackoff starts at min and doubles up to max
+func NewBackoff(min time.Duration, max time.Duration) *Backoff {
+	return &Backoff{min: min, max: max}
+}
+
+// Next returns the delay before the next attempt, a random value
+// between half and the full current delay
+func (b *Backoff) Next() time.Duration {
+	if b.current == 0 {
+		b.current = b.min
+	} else {
+		b.current *= 2
+	}
+	if b.current > b.max {
+		b.current = b.max
+	}
+
+	half := b.current / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)) // #nosec
+}
+
+// Reset starts over at min
+func (b *Backoff) Reset() {
+	b.current = 0
+}
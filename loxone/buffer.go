@@ -0,0 +1,42 @@
+package loxone
+
+import (
+	"github.com/XciD/loxone-ws/events"
+)
+
+// EventBuffer drains an event channel into memory until it is stopped
+type EventBuffer struct {
+	events []*events.Event
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// NewEventBuffer starts buffering the events of source
+func NewEventBuffer(source <-chan *events.Event) *EventBuffer {
+	b := &EventBuffer{
+		events: make([]*events.Event, 0),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(b.done)
+		for {
+			select {
+			case <-b.quit:
+				return
+			case event := <-source:
+				b.events = append(b.events, event)
+			}
+		}
+	}()
+
+	return b
+}
+
+// Stop ends the buffering and returns the events received so far, in order
+func (b *EventBuffer) Stop() []*events.Event {
+	close(b.quit)
+	<-b.done
+	return b.events
+}
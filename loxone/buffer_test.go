@@ -0,0 +1,44 @@
+package loxone
+
+import (
+	"testing"
+	"time"
+
+	"github.com/XciD/loxone-ws/events"
+)
+
+func TestEventBufferKeepsEarlyEvents(t *testing.T) {
+	// loxone-ws hands out events on an unbuffered channel, like here
+	source := make(chan *events.Event)
+	buffer := NewEventBuffer(source)
+
+	sent := []*events.Event{
+		{UUID: "a", Value: 1},
+		{UUID: "b", Value: 2},
+		{UUID: "a", Value: 3},
+	}
+	for _, event := range sent {
+		select {
+		case source <- event:
+		case <-time.After(time.Second):
+			t.Fatalf("event %s blocked while buffering", event.UUID)
+		}
+	}
+
+	buffered := buffer.Stop()
+	if len(buffered) != len(sent) {
+		t.Fatalf("got %d buffered events, want %d", len(buffered), len(sent))
+	}
+	for i, event := range buffered {
+		if event != sent[i] {
+			t.Errorf("event %d is %s=%v, want %s=%v", i, event.UUID, event.Value, sent[i].UUID, sent[i].Value)
+		}
+	}
+
+	// Once stopped the events are left to the reader of the channel again
+	select {
+	case source <- &events.Event{UUID: "c"}:
+		t.Error("event consumed after Stop")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
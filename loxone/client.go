@@ -0,0 +1,150 @@
+// Package loxone connects to Miniservers on top of loxone-ws
+package loxone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	loxonews "github.com/XciD/loxone-ws"
+)
+
+const (
+	// ProbeInterval is how often Watch checks the Miniserver still answers by default
+	ProbeInterval = 30 * time.Second
+	// ProbeTimeout is how long a command may take by default before the Miniserver is considered gone
+	ProbeTimeout = 10 * time.Second
+	apiCommand   = "jdev/cfg/api"
+)
+
+// Client is a connection to a Miniserver, it serializes the commands sent
+// as loxone-ws hands an answer to whichever command waits for one first
+type Client struct {
+	*loxonews.Loxone
+	commands sync.Mutex
+	// Timeout is how long a command may take, ProbeTimeout by default
+	Timeout time.Duration
+	// key encrypts the commands after EncryptCommands
+	key *commandKey
+}
+
+// Connect logs in to the Miniserver at address, a host and port, over TLS
+// if it's secure, with a token of the given permission
+func Connect(address string, secure bool, user string, password string, permission int) (*Client, error) {
+	options := loxonews.Options{Dialer: miniserverDialers[secure], Permission: permission}
+	if transport, ok := miniserverTransports[secure]; ok {
+		options.HTTPClient = &http.Client{Transport: transport}
+	}
+	lox, err := loxonews.NewWithOptions(address, user, password, options)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Loxone: lox, Timeout: ProbeTimeout}, nil
+}
+
+// Command sends a command and decodes its answer into value, commands that
+// change the state of a control are refused
+func (c *Client) Command(cmd string, value interface{}) error {
+	err := checkReadOnly(cmd)
+	if err != nil {
+		return err
+	}
+	return c.send(cmd, value)
+}
+
+// send sends any command and decodes its answer into value
+func (c *Client) send(cmd string, value interface{}) error {
+	c.commands.Lock()
+	defer c.commands.Unlock()
+	cmd, err := c.encrypt(cmd)
+	if err != nil {
+		return err
+	}
+	_, err = c.SendCommand(cmd, value)
+	return err
+}
+
+// RegisterEvents asks the Miniserver to send events, like the other commands
+// it waits for the commands before
+func (c *Client) RegisterEvents() error {
+	c.commands.Lock()
+	defer c.commands.Unlock()
+	return c.Loxone.RegisterEvents()
+}
+
+// RefreshToken extends the token of the login, it holds the lock of the
+// commands until the answer and gives up after Timeout
+func (c *Client) RefreshToken() error {
+	result := make(chan error, 1)
+	go func() {
+		c.commands.Lock()
+		defer c.commands.Unlock()
+		result <- c.Loxone.RefreshToken()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(c.Timeout):
+		return errors.New("timeout waiting for the Miniserver")
+	}
+}
+
+// SimpleCommand sends a command with a text answer, it gives up after Timeout
+func (c *Client) SimpleCommand(cmd string) (*loxonews.SimpleValue, error) {
+	return c.simpleCommand(cmd, c.Command)
+}
+
+// simpleCommand sends a command with send and gives up after Timeout. The
+// command keeps waiting for its answer meanwhile, it holds the lock of the
+// commands until then so a late answer isn't taken by the next command.
+func (c *Client) simpleCommand(cmd string, send func(string, interface{}) error) (*loxonews.SimpleValue, error) {
+	type answer struct {
+		value *loxonews.SimpleValue
+		err   error
+	}
+	result := make(chan answer, 1)
+	go func() {
+		value := &loxonews.SimpleValue{}
+		err := send(cmd, value)
+		result <- answer{value, err}
+	}()
+
+	select {
+	case a := <-result:
+		return a.value, a.err
+	case <-time.After(c.Timeout):
+		return nil, errors.New("timeout waiting for the Miniserver")
+	}
+}
+
+// Probe sends a cheap command to check the Miniserver still answers,
+// the answer carries the serial number and firmware version
+func (c *Client) Probe() (*loxonews.SimpleValue, error) {
+	return c.SimpleCommand(apiCommand)
+}
+
+// Watch probes the Miniserver every interval until the context is done, it
+// returns the error of the first failed probe and calls alive after the
+// others. The probes keep the connection alive, the Miniserver closes it
+// after five minutes without a message.
+func (c *Client) Watch(ctx context.Context, interval time.Duration, alive func()) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			_, err := c.Probe()
+			if err != nil {
+				return fmt.Errorf("keepalive failed: %v", err)
+			}
+			alive()
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package loxone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	dateCommand = "jdev/sys/date"
+	timeCommand = "jdev/sys/time"
+)
+
+// dateLayouts are the formats the Miniserver answers its date in
+var dateLayouts = []string{"2006-01-02", "02.01.2006", "01/02/2006"}
+
+// Clock reads the local date and time of the Miniserver, in its time zone
+// location, and returns them with the time of the exporter halfway through
+// the request of the time. The Miniserver answers in whole seconds.
+func (c *Client) Clock(location *time.Location) (miniserver time.Time, local time.Time, err error) {
+	date, err := c.SimpleCommand(dateCommand)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	start := time.Now()
+	clock, err := c.SimpleCommand(timeCommand)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	local = start.Add(time.Since(start) / 2)
+	after, err := c.SimpleCommand(dateCommand)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if after.Value != date.Value {
+		return time.Time{}, time.Time{}, errors.New("the date changed while reading the clock")
+	}
+
+	miniserver, err = parseClock(date.Value, clock.Value, location)
+	return miniserver, local, err
+}
+
+// parseClock returns the time of a date and time answer
+func parseClock(date string, clock string, location *time.Location) (time.Time, error) {
+	date, clock = strings.TrimSpace(date), strings.TrimSpace(clock)
+	for _, layout := range dateLayouts {
+		t, err := time.ParseInLocation(layout+" 15:04:05", date+" "+clock, location)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unexpected date %q and time %q", date, clock)
+}
@@ -0,0 +1,41 @@
+package loxone
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const cloudDNSURL = "http://dns.loxonecloud.com/"
+
+// ResolveCloudDNS asks the Loxone Cloud DNS for the address of a Miniserver,
+// it answers with a redirect to the external address and port. The result is
+// a host for MiniserverAddress, wss:// if the Miniserver is reachable with TLS.
+func ResolveCloudDNS(ctx context.Context, serial string) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, cloudDNSURL+url.PathEscape(serial), nil)
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{
+		Timeout: ProbeTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	location, err := response.Location()
+	if err != nil {
+		return "", fmt.Errorf("cloud DNS has no address for %s (status %d)", serial, response.StatusCode)
+	}
+	if location.Scheme == "https" {
+		return "wss://" + location.Host, nil
+	}
+	return location.Host, nil
+}
@@ -0,0 +1,161 @@
+package loxone
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/gorilla/websocket"
+)
+
+// miniserverDialers and miniserverTransports carry the traffic to Miniservers,
+// by whether it's wrapped in TLS. loxone-ws always uses ws:// and http://, so
+// the secure ones wrap the connections in TLS themselves. They are the defaults
+// of loxone-ws and net/http until ConfigureDialer is called. Other outbound
+// requests, like the Cloud DNS and the sinks, don't use them.
+var (
+	miniserverDialers    map[bool]*websocket.Dialer
+	miniserverTransports map[bool]http.RoundTripper
+)
+
+// MiniserverAddress strips the scheme of a configured host, wss:// and https://
+// hosts are dialed with TLS and default to port 443
+func MiniserverAddress(host string) (string, bool) {
+	secure := false
+	for _, scheme := range []string{"wss://", "https://"} {
+		if strings.HasPrefix(host, scheme) {
+			host, secure = strings.TrimPrefix(host, scheme), true
+		}
+	}
+	for _, scheme := range []string{"ws://", "http://"} {
+		host = strings.TrimPrefix(host, scheme)
+	}
+	host = strings.TrimSuffix(host, "/")
+
+	if !secure {
+		return host, false
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "443")
+	}
+	return host, true
+}
+
+// ConfigureDialer applies the TCP dial, TLS and proxy settings to the
+// websocket dialer and HTTP transport of the Miniserver connections
+func ConfigureDialer(cfg *config.Config) error {
+	dialer := &net.Dialer{
+		Timeout: cfg.DialTimeout,
+	}
+
+	if cfg.LocalAddr != "" {
+		ip := net.ParseIP(cfg.LocalAddr)
+		if ip == nil {
+			return fmt.Errorf("invalid local address %q", cfg.LocalAddr)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return err
+	}
+
+	if cfg.ProxyURL != "" {
+		if _, err := url.Parse(cfg.ProxyURL); err != nil {
+			return fmt.Errorf("invalid proxy URL: %v", err)
+		}
+	}
+
+	// dial picks the proxy of Miniservers, it also wraps their connections in TLS
+	dial := func(secure bool) func(ctx context.Context, network string, address string) (net.Conn, error) {
+		return func(ctx context.Context, network string, address string) (net.Conn, error) {
+			return dialMiniserver(ctx, dialer, tlsConfig, cfg.ProxyURL, secure, network, address)
+		}
+	}
+
+	miniserverDialers = make(map[bool]*websocket.Dialer)
+	miniserverTransports = make(map[bool]http.RoundTripper)
+	for _, secure := range []bool{false, true} {
+		miniserverDialers[secure] = &websocket.Dialer{
+			NetDialContext:   dial(secure),
+			HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = dial(secure)
+		transport.Proxy = nil
+		miniserverTransports[secure] = transport
+	}
+	return nil
+}
+
+// dialMiniserver connects to a Miniserver, through the proxy if any, and
+// wraps the connection in TLS if it's secure
+func dialMiniserver(ctx context.Context, dialer *net.Dialer, tlsConfig *tls.Config, proxy string, secure bool, network string, address string) (net.Conn, error) {
+	proxyURL, err := proxyFor(proxy, address, secure)
+	if err != nil {
+		return nil, err
+	}
+	var conn net.Conn
+	if proxyURL != nil {
+		conn, err = dialProxy(ctx, dialer, proxyURL, network, address)
+	} else {
+		conn, err = dialer.DialContext(ctx, network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !secure {
+		return conn, nil
+	}
+
+	c := tlsConfig.Clone()
+	if c.ServerName == "" {
+		c.ServerName, _, _ = net.SplitHostPort(address)
+	}
+	tlsConn := tls.Client(conn, c)
+	err = tlsConn.HandshakeContext(ctx)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// newTLSConfig builds the TLS settings of wss:// Miniservers
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, // #nosec
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,35 @@
+package loxone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+func TestDialerWrapsSecureConnections(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "https://")
+
+	cfg := &config.Config{}
+	cfg.TLS.InsecureSkipVerify = true
+	err := ConfigureDialer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// loxone-ws always asks for http://, the dialer of secure Miniservers adds TLS
+	for _, secure := range []bool{true, false} {
+		client := &http.Client{Transport: miniserverTransports[secure]}
+		response, err := client.Get("http://" + address + "/")
+		if err == nil {
+			response.Body.Close()
+		}
+		if ok := err == nil && response.StatusCode == http.StatusOK; ok != secure {
+			t.Errorf("secure %t: request succeeded %t, err %v", secure, ok, err)
+		}
+	}
+}
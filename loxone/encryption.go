@@ -0,0 +1,71 @@
+package loxone
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	loxonews "github.com/XciD/loxone-ws"
+	"github.com/XciD/loxone-ws/crypto"
+)
+
+const (
+	publicKeyCommand   = "jdev/sys/getPublicKey"
+	keyExchangeCommand = "jdev/sys/keyexchange/%s"
+	encryptedCommand   = "jdev/sys/enc/%s"
+)
+
+// commandKey is the AES key and IV the commands are encrypted with
+type commandKey struct {
+	key  string
+	iv   string
+	salt string
+}
+
+// EncryptCommands exchanges an AES key with the Miniserver, the jdev commands
+// sent afterwards are encrypted with it. loxone-ws only encrypts the login,
+// Miniservers that don't allow unencrypted connections need the commands
+// encrypted as well. The structure file is downloaded in clear text.
+func (c *Client) EncryptCommands() error {
+	c.commands.Lock()
+	defer c.commands.Unlock()
+
+	answer := &loxonews.SimpleValue{}
+	_, err := c.SendCommand(publicKeyCommand, answer)
+	if err != nil {
+		return fmt.Errorf("unable to read the public key: %v", err)
+	}
+	publicKey, err := crypto.BytesToPublicKey(answer.Value)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %v", err)
+	}
+
+	key := &commandKey{key: crypto.CreateEncryptKey(32), iv: crypto.CreateEncryptKey(16), salt: crypto.CreateEncryptKey(2)}
+	session, err := crypto.EncryptWithPublicKey([]byte(key.key+":"+key.iv), publicKey)
+	if err != nil {
+		return err
+	}
+	_, err = c.SendCommand(fmt.Sprintf(keyExchangeCommand, session), &loxonews.SimpleValue{})
+	if err != nil {
+		return fmt.Errorf("key exchange failed: %v", err)
+	}
+	c.key = key
+	return nil
+}
+
+// encrypt returns the command to send for a command, it's the command itself
+// until EncryptCommands was called
+func (c *Client) encrypt(cmd string) (string, error) {
+	if c.key == nil || !strings.HasPrefix(cmd, "jdev/") {
+		return cmd, nil
+	}
+	cipher, err := crypto.EncryptAES(fmt.Sprintf("salt/%s/%s", c.key.salt, cmd), c.key.key, c.key.iv)
+	if err != nil {
+		return "", err
+	}
+	if cipher == "" {
+		return "", errors.New("unable to encrypt the command")
+	}
+	return fmt.Sprintf(encryptedCommand, url.QueryEscape(cipher)), nil
+}
@@ -0,0 +1,35 @@
+package loxone
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	ioStateCommand = "jdev/sps/io/%s/state"
+	ioCommand      = "jdev/sps/io/%s/%s"
+)
+
+// ControlValue reads the current value of a control, by name or UUID
+func (c *Client) ControlValue(control string) (float64, error) {
+	value, err := c.SimpleCommand(fmt.Sprintf(ioStateCommand, control))
+	if err != nil {
+		return 0, err
+	}
+	number, err := strconv.ParseFloat(value.Value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s has no numeric value: %q", control, value.Value)
+	}
+	return number, nil
+}
+
+// ControlCommand sends a command to a control, e.g. on, off or
+// setpoint/21. Unlike the other commands it changes the state of the control,
+// it's only sent on behalf of the write API.
+func (c *Client) ControlCommand(uuid string, command string) (string, error) {
+	value, err := c.simpleCommand(fmt.Sprintf(ioCommand, uuid, command), c.send)
+	if err != nil {
+		return "", err
+	}
+	return value.Value, nil
+}
@@ -0,0 +1,103 @@
+package loxone
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// proxyFor returns the proxy the Miniserver at address is reached through,
+// the configured one or the one of HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+// It returns nil for a direct connection.
+func proxyFor(proxyURL string, address string, secure bool) (*url.URL, error) {
+	if proxyURL != "" {
+		return url.Parse(proxyURL)
+	}
+	target := &url.URL{Scheme: "http", Host: address}
+	if secure {
+		target.Scheme = "https"
+	}
+	return httpproxy.FromEnvironment().ProxyFunc()(target)
+}
+
+// dialProxy connects to address through a SOCKS5 or HTTP CONNECT proxy
+func dialProxy(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, network string, address string) (net.Conn, error) {
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		socks, err := proxy.FromURL(proxyURL, dialer)
+		if err != nil {
+			return nil, err
+		}
+		return socks.(proxy.ContextDialer).DialContext(ctx, network, address)
+	case "http", "https":
+		return dialConnect(ctx, dialer, proxyURL, network, address)
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q, use http, https or socks5", proxyURL.Scheme)
+}
+
+// dialConnect opens a tunnel to address with an HTTP CONNECT request
+func dialConnect(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, network string, address string) (net.Conn, error) {
+	proxyAddress := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddress = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	conn, err := dialer.DialContext(ctx, network, proxyAddress)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		err = tlsConn.HandshakeContext(ctx)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	err = request.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// The Miniserver only talks once spoken to, nothing follows the answer yet
+	response, err := http.ReadResponse(bufio.NewReader(conn), request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", proxyURL.Host, address, response.Status)
+	}
+	return conn, nil
+}
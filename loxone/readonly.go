@@ -0,0 +1,50 @@
+package loxone
+
+import (
+	"fmt"
+	"strings"
+)
+
+// readCommands are the last segment of the jdev/sps/io and jdev/sps/ios
+// commands that read a control, the others change its state
+var readCommands = map[string]bool{"state": true, "all": true, "securedDetails": true}
+
+// User is the currentUser of the msInfo block of the structure file, the
+// user the exporter is logged in as
+type User struct {
+	Name  string
+	Admin bool
+	// Rights is the bitmask of the rights of the user
+	Rights int64
+}
+
+// checkReadOnly refuses commands that change the state of a control, the
+// exporter only reads the Miniserver
+func checkReadOnly(cmd string) error {
+	// The Miniserver takes dev/ as well as jdev/, with or without a leading slash
+	path := strings.TrimPrefix(strings.ToLower(strings.TrimLeft(cmd, "/")), "j")
+	if !strings.HasPrefix(path, "dev/sps/io/") && !strings.HasPrefix(path, "dev/sps/ios/") {
+		return nil
+	}
+	segments := strings.Split(cmd, "/")
+	if !readCommands[segments[len(segments)-1]] {
+		return fmt.Errorf("refusing to send %s, it changes the state of a control", cmd)
+	}
+	return nil
+}
+
+// CurrentUser returns the user of the structure file, false if the firmware
+// doesn't tell it
+func (s *Structure) CurrentUser() (*User, bool) {
+	current, ok := s.MsInfo["currentUser"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	user := &User{}
+	user.Name, _ = current["name"].(string)
+	user.Admin, _ = current["isAdmin"].(bool)
+	if rights, ok := current["userRights"].(float64); ok {
+		user.Rights = int64(rights)
+	}
+	return user, true
+}
@@ -0,0 +1,26 @@
+package loxone
+
+import "testing"
+
+func TestCheckReadOnly(t *testing.T) {
+	tests := []struct {
+		cmd     string
+		allowed bool
+	}{
+		{"jdev/sps/io/0f000000-0000-0000-ffff000000000001/state", true},
+		{"jdev/sps/io/0f000000-0000-0000-ffff000000000001/securedDetails", true},
+		{"jdev/sps/enablebinstatusupdate", true},
+		{"jdev/cfg/version", true},
+		{"jdev/sps/io/0f000000-0000-0000-ffff000000000001/on", false},
+		{"jdev/sps/ios/hash/0f000000-0000-0000-ffff000000000001/pulse", false},
+		{"dev/sps/io/0f000000-0000-0000-ffff000000000001/on", false},
+		{"/dev/sps/io/0f000000-0000-0000-ffff000000000001/on", false},
+		{"JDEV/SPS/IO/0f000000-0000-0000-ffff000000000001/on", false},
+	}
+	for _, test := range tests {
+		err := checkReadOnly(test.cmd)
+		if allowed := err == nil; allowed != test.allowed {
+			t.Errorf("checkReadOnly(%q) allowed it: %t, want %t", test.cmd, allowed, test.allowed)
+		}
+	}
+}
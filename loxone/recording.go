@@ -0,0 +1,71 @@
+package loxone
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxRecordLine is the longest line of a recording, the structure file
+// of large installations takes a few megabytes
+const maxRecordLine = 64 * 1024 * 1024
+
+// RecordEntry is a line of a recording. The first one holds the structure
+// file, the following ones an event each.
+type RecordEntry struct {
+	Time       time.Time       `json:"time"`
+	Miniserver string          `json:"miniserver,omitempty"`
+	Structure  json.RawMessage `json:"structure,omitempty"`
+	UUID       string          `json:"uuid,omitempty"`
+	Value      float64         `json:"value"`
+	// Text is set for text events
+	Text *string `json:"text,omitempty"`
+}
+
+// RecordingReader reads the entries of a recording, one JSON object per line
+type RecordingReader struct {
+	scanner *bufio.Scanner
+}
+
+// NewRecordingReader reads a recording from r
+func NewRecordingReader(r io.Reader) *RecordingReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordLine)
+	return &RecordingReader{scanner: scanner}
+}
+
+// Next returns the next entry, io.EOF at the end of the recording
+func (r *RecordingReader) Next() (*RecordEntry, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	entry := &RecordEntry{}
+	err := json.Unmarshal(r.scanner.Bytes(), entry)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// RecordingWriter writes the entries of a recording
+type RecordingWriter struct {
+	sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewRecordingWriter writes a recording to w
+func NewRecordingWriter(w io.Writer) *RecordingWriter {
+	return &RecordingWriter{encoder: json.NewEncoder(w)}
+}
+
+// Write appends an entry to the recording
+func (w *RecordingWriter) Write(entry *RecordEntry) error {
+	w.Lock()
+	defer w.Unlock()
+	return w.encoder.Encode(entry)
+}
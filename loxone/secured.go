@@ -0,0 +1,67 @@
+package loxone
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // #nosec, the Miniserver asks for SHA1 on older firmware
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+const (
+	visuSaltCommand       = "jdev/sys/getvisusalt/%s"
+	securedDetailsCommand = "jdev/sps/ios/%s/%s/securedDetails"
+)
+
+// visuSalt is the answer of jdev/sys/getvisusalt, the key is only valid for one command
+type visuSalt struct {
+	Key     string `mapstructure:"key"`
+	Salt    string `mapstructure:"salt"`
+	HashAlg string `mapstructure:"hashAlg"`
+}
+
+// visuHash hashes the visualization password of the user for a secured command
+func (c *Client) visuHash(user string, password string) (string, error) {
+	salt := &visuSalt{}
+	err := c.Command(fmt.Sprintf(visuSaltCommand, user), salt)
+	if err != nil {
+		return "", err
+	}
+	key, err := hex.DecodeString(salt.Key)
+	if err != nil {
+		return "", fmt.Errorf("invalid visu salt key: %v", err)
+	}
+
+	newHash := sha1.New
+	if strings.EqualFold(salt.HashAlg, "SHA256") {
+		newHash = sha256.New
+	}
+	pwHash := newHash()
+	pwHash.Write([]byte(password + ":" + salt.Salt))
+	mac := hmac.New(func() hash.Hash { return newHash() }, key)
+	mac.Write([]byte(strings.ToUpper(hex.EncodeToString(pwHash.Sum(nil)))))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// SecuredDetails reads the secured details of a control, e.g. the video
+// stream of an intercom, they need the visualization password of the user
+func (c *Client) SecuredDetails(uuid string, user string, visuPassword string) (map[string]interface{}, error) {
+	visuHash, err := c.visuHash(user, visuPassword)
+	if err != nil {
+		return nil, err
+	}
+	value, err := c.SimpleCommand(fmt.Sprintf(securedDetailsCommand, visuHash, uuid))
+	if err != nil {
+		return nil, err
+	}
+
+	details := make(map[string]interface{})
+	err = json.Unmarshal([]byte(value.Value), &details)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secured details: %v", err)
+	}
+	return details, nil
+}
@@ -0,0 +1,122 @@
+package loxone
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statisticsTimeFormat is the format of the times of statistics files, in
+// the time zone of the Miniserver
+const statisticsTimeFormat = "2006-01-02 15:04:05"
+
+// Statistic are the statistics settings of a control, the Miniserver keeps
+// a statistics file per control and month
+type Statistic struct {
+	Frequency int               `json:"frequency"`
+	Outputs   []StatisticOutput `json:"outputs"`
+}
+
+// StatisticOutput is a value recorded by the statistics of a control
+type StatisticOutput struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Format string `json:"format"`
+}
+
+// StatisticPoint is an entry of a statistics file, with a value per output
+type StatisticPoint struct {
+	Time   time.Time
+	Values []float64
+}
+
+// statisticsFile is a statistics file, e.g.
+// <Statistics><S T="2024-01-01 00:00:00" V="21.5" V2="3"/></Statistics>
+type statisticsFile struct {
+	Points []struct {
+		Attrs []xml.Attr `xml:",any,attr"`
+	} `xml:"S"`
+}
+
+// FetchStatistics downloads the statistics file of a control for the month
+// of the Miniserver at address, a host and port as of MiniserverAddress, over
+// TLS if it's secure. It returns no points if the control has no statistics that month.
+func FetchStatistics(ctx context.Context, address string, secure bool, user string, password string, uuid string, month time.Time, location *time.Location) ([]StatisticPoint, error) {
+	// Connections to secure hosts are wrapped in TLS by the dialer
+	url := fmt.Sprintf("http://%s/stats/%s.%s.xml", address, uuid, month.Format("200601"))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.SetBasicAuth(user, password)
+
+	client := &http.Client{Timeout: time.Minute, Transport: miniserverTransports[secure]}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case response.StatusCode/100 != 2:
+		return nil, fmt.Errorf("unexpected status %s for %s", response.Status, url)
+	}
+	return parseStatistics(response.Body, location)
+}
+
+// parseStatistics decodes a statistics file, the values of the outputs are
+// the attributes V, V2, V3...
+func parseStatistics(r io.Reader, location *time.Location) ([]StatisticPoint, error) {
+	file := &statisticsFile{}
+	err := xml.NewDecoder(r).Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]StatisticPoint, 0, len(file.Points))
+	for _, entry := range file.Points {
+		point := StatisticPoint{}
+		for _, attr := range entry.Attrs {
+			name := attr.Name.Local
+			switch {
+			case name == "T":
+				point.Time, err = time.ParseInLocation(statisticsTimeFormat, attr.Value, location)
+				if err != nil {
+					return nil, err
+				}
+			case strings.HasPrefix(name, "V"):
+				index := 0
+				if name != "V" {
+					index, err = strconv.Atoi(strings.TrimPrefix(name, "V"))
+					if err != nil {
+						continue
+					}
+					index--
+				}
+				if index < 0 {
+					continue
+				}
+				value, err := strconv.ParseFloat(attr.Value, 64)
+				if err != nil {
+					return nil, err
+				}
+				for len(point.Values) <= index {
+					point.Values = append(point.Values, 0)
+				}
+				point.Values[index] = value
+			}
+		}
+		if point.Time.IsZero() {
+			continue
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
@@ -0,0 +1,63 @@
+package loxone
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	cpuCommand   = "jdev/sys/lastcpu"
+	heapCommand  = "jdev/sys/heap"
+	tasksCommand = "jdev/sys/numtasks"
+)
+
+// statRegex matches the leading number and unit of a statistics answer, e.g. "12%" or "2356/6384kB"
+var statRegex = regexp.MustCompile(`^\s*([0-9.]+)\D*?(?:/[0-9.]+)?\s*([kKmM]?B)?\s*$`)
+
+// SystemStats are the load figures of the Miniserver
+type SystemStats struct {
+	CPUPercent float64
+	HeapBytes  float64
+	Tasks      float64
+}
+
+// SystemStats reads the CPU load, heap usage and number of tasks of the Miniserver
+func (c *Client) SystemStats() (*SystemStats, error) {
+	stats := &SystemStats{}
+	for cmd, target := range map[string]*float64{
+		cpuCommand:   &stats.CPUPercent,
+		heapCommand:  &stats.HeapBytes,
+		tasksCommand: &stats.Tasks,
+	} {
+		value, err := c.SimpleCommand(cmd)
+		if err != nil {
+			return nil, err
+		}
+		*target, err = parseStat(value.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", cmd, err)
+		}
+	}
+	return stats, nil
+}
+
+// parseStat returns the number of a statistics answer, in bytes if it has a unit
+func parseStat(value string) (float64, error) {
+	match := statRegex.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("unexpected answer %q", value)
+	}
+	number, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	switch strings.ToLower(match[2]) {
+	case "kb":
+		number *= 1024
+	case "mb":
+		number *= 1024 * 1024
+	}
+	return number, nil
+}
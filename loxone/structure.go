@@ -0,0 +1,118 @@
+package loxone
+
+import (
+	"encoding/json"
+
+	loxonews "github.com/XciD/loxone-ws"
+)
+
+const (
+	structureCommand        = "data/LoxAPP3.json"
+	structureVersionCommand = "jdev/sps/LoxAPPversion3"
+)
+
+// ControlDetails are the parts of a control's details loxone-ws doesn't decode
+type ControlDetails struct {
+	Format       string `json:"format"`
+	ActualFormat string `json:"actualFormat"`
+	TotalFormat  string `json:"totalFormat"`
+	// SecuredDetails tells the control has details only readable with the visualization password
+	SecuredDetails bool `json:"securedDetails"`
+}
+
+// StateFormat returns the display format of a state, e.g. %.1f°
+func (d ControlDetails) StateFormat(state string) string {
+	switch state {
+	case "value":
+		return d.Format
+	case "actual", "actualProduction":
+		return d.ActualFormat
+	case "total", "totalProduction":
+		return d.TotalFormat
+	}
+	return ""
+}
+
+// SubControl is a control within a control, e.g. the ones of an IRoomControllerV2
+type SubControl struct {
+	Name        string
+	Type        string
+	States      map[string]interface{}
+	Details     ControlDetails         `json:"details"`
+	SubControls map[string]*SubControl `json:"subControls"`
+}
+
+// Structure is the structure file with the details of every control
+type Structure struct {
+	*loxonews.Config
+	Details map[string]ControlDetails
+	// SubControls are the subcontrols by UUID of their control
+	SubControls map[string]map[string]*SubControl
+	// Statistics are the statistics settings by UUID of their control
+	Statistics map[string]*Statistic
+	// Ratings are the default ratings by UUID of their control, how high
+	// the apps list the control in its room or category
+	Ratings map[string]int
+	// OperatingModes name the operating modes of daytimer schedules by number
+	OperatingModes map[string]string
+	// Raw is the structure file as downloaded
+	Raw json.RawMessage
+}
+
+// GetStructure downloads the structure file, it decodes it like
+// loxone-ws does and keeps the control details
+func GetStructure(lox *Client) (*Structure, error) {
+	var raw json.RawMessage
+	err := lox.Command(structureCommand, &raw)
+	if err != nil {
+		return nil, err
+	}
+	return ParseStructure(raw)
+}
+
+// ParseStructure decodes a structure file
+func ParseStructure(raw []byte) (*Structure, error) {
+	result := &Structure{Config: &loxonews.Config{}, Details: make(map[string]ControlDetails), SubControls: make(map[string]map[string]*SubControl), Statistics: make(map[string]*Statistic), Ratings: make(map[string]int), Raw: raw}
+	err := json.Unmarshal(raw, result.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	var details struct {
+		OperatingModes map[string]string `json:"operatingModes"`
+		Controls       map[string]struct {
+			Details       ControlDetails         `json:"details"`
+			SubControls   map[string]*SubControl `json:"subControls"`
+			Statistic     *Statistic             `json:"statistic"`
+			DefaultRating int                    `json:"defaultRating"`
+		} `json:"controls"`
+	}
+	err = json.Unmarshal(raw, &details)
+	if err != nil {
+		return nil, err
+	}
+	result.OperatingModes = details.OperatingModes
+	for uuid, control := range details.Controls {
+		result.Details[uuid] = control.Details
+		if len(control.SubControls) > 0 {
+			result.SubControls[uuid] = control.SubControls
+		}
+		if control.Statistic != nil {
+			result.Statistics[uuid] = control.Statistic
+		}
+		if control.DefaultRating != 0 {
+			result.Ratings[uuid] = control.DefaultRating
+		}
+	}
+	return result, nil
+}
+
+// StructureVersion returns the last modification of the structure file,
+// it's the lastModified of the structure
+func StructureVersion(lox *Client) (string, error) {
+	value, err := lox.SimpleCommand(structureVersionCommand)
+	if err != nil {
+		return "", err
+	}
+	return value.Value, nil
+}
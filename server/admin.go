@@ -0,0 +1,61 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AdminAuth protects admin endpoints with basic auth when admin credentials are configured
+func AdminAuth(cfg *config.Config, next http.Handler) http.Handler {
+	if cfg.AdminUser == "" {
+		return next
+	}
+	return basicAuth(cfg.AdminUser, cfg.AdminPassword, next)
+}
+
+// basicAuth lets requests with these credentials through
+func basicAuth(expectedUser string, expectedPassword string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(expectedUser)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(expectedPassword)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="loxone-prometheus-exporter"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LogLevelHandler returns the current log level on GET and changes it on PUT
+func LogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		fmt.Fprintln(w, log.GetLevel().String())
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		level, err := log.ParseLevel(strings.TrimSpace(string(body)))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.SetLevel(level)
+		log.Infof("Log level set to %s", level)
+		fmt.Fprintln(w, level.String())
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
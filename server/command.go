@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// commandPath matches /api/v1/controls/{uuid}/cmd
+var commandPath = regexp.MustCompile(`^/api/v1/controls/([0-9a-fA-F-]+)/cmd$`)
+
+// commandRequest is the body of a command, e.g. {"command": "on"}
+type commandRequest struct {
+	Command string `json:"command"`
+}
+
+// commandAnswer is the answer of the Miniserver to a command
+type commandAnswer struct {
+	Miniserver string `json:"miniserver"`
+	Control    string `json:"control"`
+	Command    string `json:"command"`
+	Value      string `json:"value"`
+}
+
+// CommandHandler sends the command of a POST to /api/v1/controls/{uuid}/cmd
+// to the control, on the Miniserver that has it
+func CommandHandler(w http.ResponseWriter, r *http.Request) {
+	match := commandPath.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	request := commandRequest{}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&request)
+	if err != nil {
+		http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validCommand(request.Command) {
+		http.Error(w, "invalid command", http.StatusBadRequest)
+		return
+	}
+
+	uuid := match[1]
+	miniserver, value, err := collector.SendCommand(uuid, request.Command)
+	if err == collector.ErrUnknownControl {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Warnf("Command %s to %s failed: %v", request.Command, uuid, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Infof("Sent command %s to %s on %s", request.Command, uuid, miniserver)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(commandAnswer{Miniserver: miniserver, Control: uuid, Command: request.Command, Value: value})
+}
+
+// validCommand tells whether a command is a path below the control, e.g.
+// on or setpoint/21
+func validCommand(command string) bool {
+	if command == "" || strings.ContainsAny(command, "?#\\ \t\r\n") {
+		return false
+	}
+	for _, segment := range strings.Split(command, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+)
+
+var controlsTemplate = template.Must(template.New("controls").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Loxone Exporter - Controls</title></head>
+<body>
+<h1>Controls</h1>
+<table border="1" cellpadding="4">
+<tr><th>Miniserver</th><th>Control</th><th>Room</th><th>Category</th><th>Type</th><th>UUID</th><th>Filtered by</th><th>State</th><th>State UUID</th><th>Value</th><th>Last update</th></tr>
+{{range .}}{{$control := .}}{{range .States}}<tr>
+<td>{{$control.Miniserver}}</td><td>{{$control.Name}}</td><td>{{$control.Room}} ({{$control.RoomUUID}})</td><td>{{$control.Cat}}</td><td>{{$control.Type}}</td><td>{{$control.UUID}}</td><td>{{$control.Filtered}}</td>
+<td>{{.Name}}</td><td>{{.UUID}}</td>{{if .Exported}}{{if .LastUpdate.IsZero}}<td colspan="2">no event yet</td>{{else}}<td>{{.Value}}</td><td>{{.LastUpdate.Format "2006-01-02 15:04:05"}}</td>{{end}}{{else}}<td colspan="2">not exported</td>{{end}}
+</tr>
+{{else}}<tr>
+<td>{{.Miniserver}}</td><td>{{.Name}}</td><td>{{.Room}} ({{.RoomUUID}})</td><td>{{.Cat}}</td><td>{{.Type}}</td><td>{{.UUID}}</td><td>{{.Filtered}}</td><td colspan="4">no states</td>
+</tr>
+{{end}}{{end}}</table>
+</body>
+</html>
+`))
+
+// ControlsHandler lists all controls of the structure files with the current
+// values of their states, and whether they are filtered out
+func ControlsHandler(values *collector.ValuesCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		controlsTemplate.Execute(w, values.Controls())
+	})
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+type healthStatus struct {
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+func writeHealth(w http.ResponseWriter, code int, status healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
+
+// HealthzHandler answers as long as the process serves HTTP
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// ReadyzHandler is ready once every configured Miniserver is connected and
+// its structure file is mapped, /probe targets aren't taken into account
+func ReadyzHandler(miniservers []config.MiniserverConfig, values *collector.ValuesCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reasons := make([]string, 0)
+		for _, miniserver := range miniservers {
+			if !collector.IsConnected(miniserver.Name) {
+				reasons = append(reasons, fmt.Sprintf("%s: not connected", miniserver.Name))
+				continue
+			}
+			if !values.HasStates(miniserver.Name) {
+				reasons = append(reasons, fmt.Sprintf("%s: structure file not loaded", miniserver.Name))
+			}
+		}
+
+		if len(reasons) > 0 {
+			writeHealth(w, http.StatusServiceUnavailable, healthStatus{Status: "not ready", Reasons: reasons})
+			return
+		}
+		writeHealth(w, http.StatusOK, healthStatus{Status: "ok"})
+	})
+}
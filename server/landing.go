@@ -0,0 +1,46 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+
+	"github.com/prometheus/common/version"
+)
+
+var landingTemplate = template.Must(template.New("landing").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Loxone Exporter</title></head>
+<body>
+<h1>Loxone Exporter</h1>
+<p>Version {{.Version}}</p>
+<table border="1" cellpadding="4">
+<tr><th>Miniserver</th><th>Connected</th><th>Controls</th><th>Series</th><th>Unknown events</th></tr>
+{{range .Miniservers}}<tr><td>{{.Name}}</td><td>{{if .Connected}}yes{{else}}no{{end}}</td><td>{{.Controls}}</td><td>{{.Series}}</td><td>{{.UnknownEvents}}</td></tr>
+{{else}}<tr><td colspan="5">No Miniserver yet</td></tr>
+{{end}}</table>
+<ul>
+<li><a href="metrics">Metrics</a></li>
+<li><a href="controls">Controls</a></li>
+<li><a href="healthz">Health</a></li>
+<li><a href="readyz">Readiness</a></li>
+</ul>
+</body>
+</html>
+`))
+
+// LandingHandler serves a status page at / and 404 on other unknown paths
+func LandingHandler(values *collector.ValuesCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		landingTemplate.Execute(w, struct {
+			Version     string
+			Miniservers []collector.MiniserverStatus
+		}{version.Info(), values.Status()})
+	})
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const defaultPrefix = "loxone"
+
+// prefixGatherer replaces the loxone namespace of the gathered metrics
+type prefixGatherer struct {
+	gatherer prometheus.Gatherer
+	prefix   string
+}
+
+// Gather implements prometheus.Gatherer
+func (g *prefixGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	for _, family := range families {
+		if name := family.GetName(); strings.HasPrefix(name, defaultPrefix+"_") {
+			name = g.prefix + strings.TrimPrefix(name, defaultPrefix)
+			family.Name = &name
+		}
+	}
+	return families, err
+}
+
+// Gatherer returns the default gatherer with the configured metrics prefix
+func Gatherer(cfg *config.Config) prometheus.Gatherer {
+	if cfg.Metrics.Prefix == "" || cfg.Metrics.Prefix == defaultPrefix {
+		return prometheus.DefaultGatherer
+	}
+	return &prefixGatherer{gatherer: prometheus.DefaultGatherer, prefix: cfg.Metrics.Prefix}
+}
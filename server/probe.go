@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultProbeTimeout = 10 * time.Second
+
+// targetGatherer only keeps the series of one Miniserver
+type targetGatherer struct {
+	gatherer   prometheus.Gatherer
+	miniserver string
+}
+
+// Gather implements prometheus.Gatherer
+func (g *targetGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*dto.MetricFamily, 0, len(families))
+	for _, family := range families {
+		metrics := make([]*dto.Metric, 0)
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == "miniserver" && pair.GetValue() == g.miniserver {
+					metrics = append(metrics, metric)
+					break
+				}
+			}
+		}
+		if len(metrics) > 0 {
+			family.Metric = metrics
+			result = append(result, family)
+		}
+	}
+	return result, nil
+}
+
+// probeTarget is a watched /probe target
+type probeTarget struct {
+	cancel     context.CancelFunc
+	lastScrape time.Time
+}
+
+// Prober connects to /probe targets on demand and keeps the connections
+// open for the following scrapes
+type Prober struct {
+	sync.Mutex
+	ctx    context.Context
+	cfg    *config.Config
+	mapper *collector.StateMapper
+	values *collector.ValuesCollector
+	// configured are the names of the configured Miniservers, they are
+	// probed through their running connection
+	configured map[string]bool
+	// allowed are the other hosts that may be probed
+	allowed map[string]bool
+	targets map[string]*probeTarget
+	running sync.WaitGroup
+}
+
+// NewProber creates the /probe handler, connections end with ctx
+func NewProber(ctx context.Context, cfg *config.Config, miniservers []config.MiniserverConfig, mapper *collector.StateMapper, values *collector.ValuesCollector) *Prober {
+	configured := make(map[string]bool, len(miniservers))
+	for _, miniserver := range miniservers {
+		configured[miniserver.Name] = true
+	}
+	allowed := make(map[string]bool, len(cfg.Probe.Targets))
+	for _, target := range cfg.Probe.Targets {
+		allowed[target] = true
+	}
+	p := &Prober{
+		ctx:        ctx,
+		cfg:        cfg,
+		mapper:     mapper,
+		values:     values,
+		configured: configured,
+		allowed:    allowed,
+		targets:    make(map[string]*probeTarget),
+	}
+	if cfg.Probe.TargetExpiry > 0 && len(allowed) > 0 {
+		p.running.Add(1)
+		go func() {
+			defer p.running.Done()
+			p.expire(cfg.Probe.TargetExpiry)
+		}()
+	}
+	return p
+}
+
+// ensure starts watching the target unless it's already watched
+func (p *Prober) ensure(target string, module config.ModuleConfig) {
+	p.Lock()
+	defer p.Unlock()
+
+	if watched, ok := p.targets[target]; ok {
+		watched.lastScrape = time.Now()
+		return
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.targets[target] = &probeTarget{cancel: cancel, lastScrape: time.Now()}
+
+	miniserver := config.MiniserverConfig{
+		Name:     target,
+		Host:     target,
+		User:     module.User,
+		Password: module.Password,
+	}
+	p.running.Add(1)
+	go func() {
+		defer p.running.Done()
+		collector.RunMiniserver(ctx, p.cfg, miniserver, p.mapper, p.values)
+
+		// Drop the series of an expired target, unless it's scraped again already
+		p.Lock()
+		defer p.Unlock()
+		if _, watched := p.targets[target]; !watched && p.ctx.Err() == nil {
+			p.values.Forget(target)
+		}
+	}()
+}
+
+// expire closes the connections to the targets not scraped for longer than expiry
+func (p *Prober) expire(expiry time.Duration) {
+	ticker := time.NewTicker(expiry / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		p.Lock()
+		for target, watched := range p.targets {
+			if time.Since(watched.lastScrape) > expiry {
+				log.WithField("miniserver", target).Infof("Closing the connection to the /probe target, not scraped for %s", expiry)
+				watched.cancel()
+				delete(p.targets, target)
+			}
+		}
+		p.Unlock()
+	}
+}
+
+// Wait blocks until the connections to all targets are closed
+func (p *Prober) Wait() {
+	p.running.Wait()
+}
+
+// ServeHTTP implements http.Handler
+func (p *Prober) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	if !p.configured[target] {
+		if !p.allowed[target] {
+			http.Error(w, "target "+strconv.Quote(target)+" is not allowed", http.StatusForbidden)
+			return
+		}
+		moduleName := r.URL.Query().Get("module")
+		module, ok := p.cfg.Module(moduleName)
+		if !ok {
+			http.Error(w, "unknown module "+strconv.Quote(moduleName), http.StatusBadRequest)
+			return
+		}
+		p.ensure(target, module)
+	}
+
+	// Give a new connection the time to map the structure file
+	timeout := defaultProbeTimeout
+	if seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64); err == nil {
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	deadline := time.Now().Add(timeout - time.Second)
+	for !p.values.HasStates(target) && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	gatherer := &targetGatherer{gatherer: Gatherer(p.cfg), miniserver: target}
+	promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
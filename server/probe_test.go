@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+func TestProbeRejectsUnlistedTargets(t *testing.T) {
+	cfg := &config.Config{User: "prometheus", Password: "secret"}
+	cfg.Probe.Targets = []string{"192.0.2.10"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	prober := NewProber(ctx, cfg, nil, nil, collector.NewValuesCollector(cfg))
+
+	recorder := httptest.NewRecorder()
+	prober.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/probe?target=192.0.2.99", nil))
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("unlisted target answered %d, want %d", recorder.Code, http.StatusForbidden)
+	}
+	if len(prober.targets) != 0 {
+		t.Errorf("unlisted target is watched")
+	}
+}
+
+func TestProbeRequiresAdminAuth(t *testing.T) {
+	cfg := &config.Config{AdminUser: "admin", AdminPassword: "secret"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	prober := NewProber(ctx, cfg, nil, nil, collector.NewValuesCollector(cfg))
+	handler := Handler(cfg, nil, nil, nil, prober)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/probe?target=192.0.2.10", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("probe without credentials answered %d, want %d", recorder.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// reloadConfig reads the config file again and applies the settings that
+// can change at runtime, the Miniserver connections stay open
+func reloadConfig(mapper *collector.StateMapper) error {
+	cfg, err := config.Reload()
+	if err != nil {
+		return err
+	}
+	err = mapper.Reload(cfg)
+	if err != nil {
+		return err
+	}
+	log.Info("Config reloaded")
+	return nil
+}
+
+// ReloadOnSignal reloads the config on SIGHUP until the context is done
+func ReloadOnSignal(ctx context.Context, mapper *collector.StateMapper) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			err := reloadConfig(mapper)
+			if err != nil {
+				log.Errorf("Unable to reload config: %v", err)
+			}
+		}
+	}
+}
+
+// ReloadHandler reloads the config on POST
+func ReloadHandler(mapper *collector.StateMapper) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			w.Header().Set("Allow", "POST, PUT")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		err := reloadConfig(mapper)
+		if err != nil {
+			log.Errorf("Unable to reload config: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
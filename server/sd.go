@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+// sdTargetGroup is a target group of the Prometheus HTTP service discovery
+type sdTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// SDHandler answers GET with a Prometheus HTTP service discovery target per
+// configured Miniserver, each one is scraped from /probe on this exporter
+func SDHandler(miniservers []config.MiniserverConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		groups := make([]sdTargetGroup, 0, len(miniservers))
+		for _, miniserver := range miniservers {
+			labels := collector.MiniserverLabels(miniserver.Name)
+			labels["miniserver"] = miniserver.Name
+			labels["__scheme__"] = scheme
+			labels["__metrics_path__"] = "/probe"
+			labels["__param_target"] = miniserver.Name
+			// The exporter is reached at the address Prometheus asked it for targets
+			groups = append(groups, sdTargetGroup{Targets: []string{r.Host}, Labels: labels})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(groups)
+	})
+}
@@ -0,0 +1,55 @@
+// Package server holds the HTTP endpoints of the exporter
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
+)
+
+// Handler routes the endpoints of the exporter
+func Handler(cfg *config.Config, miniservers []config.MiniserverConfig, mapper *collector.StateMapper, values *collector.ValuesCollector, prober *Prober) http.Handler {
+	mux := http.NewServeMux()
+	var metrics http.Handler = promhttp.HandlerFor(Gatherer(cfg), promhttp.HandlerOpts{EnableOpenMetrics: cfg.Metrics.EventTimestamps})
+	if !cfg.Web.DisableExporterMetrics {
+		metrics = promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, metrics)
+	}
+	mux.Handle("/metrics", metrics)
+	mux.Handle("/", LandingHandler(values))
+	mux.Handle("/controls", ControlsHandler(values))
+	mux.Handle("/api/v1/values", ValuesHandler(values))
+	mux.HandleFunc("/api/v1/unknown-events", UnknownEventsHandler)
+	mux.Handle("/api/v1/events", EventsHandler(mapper))
+	mux.Handle("/probe", AdminAuth(cfg, prober))
+	mux.Handle("/sd", SDHandler(miniservers))
+	mux.HandleFunc("/healthz", HealthzHandler)
+	mux.Handle("/readyz", ReadyzHandler(miniservers, values))
+	if cfg.Web.EnableLifecycle {
+		mux.Handle("/-/loglevel", AdminAuth(cfg, http.HandlerFunc(LogLevelHandler)))
+		mux.Handle("/-/reload", AdminAuth(cfg, ReloadHandler(mapper)))
+	}
+	if cfg.WriteUser != "" {
+		mux.Handle("/api/v1/controls/", basicAuth(cfg.WriteUser, cfg.WritePassword, http.HandlerFunc(CommandHandler)))
+	}
+	if cfg.Web.EnablePprof {
+		mux.Handle("/debug/pprof/", AdminAuth(cfg, http.HandlerFunc(pprof.Index)))
+		mux.Handle("/debug/pprof/cmdline", AdminAuth(cfg, http.HandlerFunc(pprof.Cmdline)))
+		mux.Handle("/debug/pprof/profile", AdminAuth(cfg, http.HandlerFunc(pprof.Profile)))
+		mux.Handle("/debug/pprof/symbol", AdminAuth(cfg, http.HandlerFunc(pprof.Symbol)))
+		mux.Handle("/debug/pprof/trace", AdminAuth(cfg, http.HandlerFunc(pprof.Trace)))
+	}
+	return mux
+}
+
+// Serve serves HTTP on the listener with the TLS and basic auth settings
+// of the exporter-toolkit web config
+func Serve(listener net.Listener, server *http.Server, cfg *config.Config) error {
+	return web.Serve(listener, server, &web.FlagConfig{WebConfigFile: &cfg.Web.Config.File}, kitLogger{})
+}
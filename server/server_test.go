@@ -0,0 +1,24 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+)
+
+func TestLifecycleEndpointsNeedFlag(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		cfg := &config.Config{}
+		cfg.Web.EnableLifecycle = enabled
+		handler := Handler(cfg, nil, nil, nil, nil)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/-/loglevel", nil))
+		served := recorder.Code == http.StatusOK
+		if served != enabled {
+			t.Errorf("with --web.enable-lifecycle=%t /-/loglevel answered %d", enabled, recorder.Code)
+		}
+	}
+}
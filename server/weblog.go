@@ -0,0 +1,34 @@
+package server
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// kitLogger passes the go-kit log lines of exporter-toolkit to logrus
+type kitLogger struct{}
+
+// Log implements the go-kit log.Logger interface
+func (kitLogger) Log(keyvals ...interface{}) error {
+	fields := log.Fields{}
+	message := ""
+	level := log.InfoLevel
+
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		key, value := fmt.Sprint(keyvals[i]), keyvals[i+1]
+		switch key {
+		case "msg":
+			message = fmt.Sprint(value)
+		case "level":
+			if parsed, err := log.ParseLevel(fmt.Sprint(value)); err == nil {
+				level = parsed
+			}
+		default:
+			fields[key] = value
+		}
+	}
+
+	log.WithFields(fields).Log(level, message)
+	return nil
+}
@@ -0,0 +1,104 @@
+package sink
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// update is a state update waiting to be forwarded
+type update struct {
+	labels prometheus.Labels
+	value  float64
+	// text is the text of text states, for sinks of texts
+	text string
+	at   time.Time
+}
+
+// batcher collects updates and flushes them when the batch is full or the
+// interval is over, in its own goroutine so publishing never blocks
+type batcher struct {
+	name     string
+	size     int
+	interval time.Duration
+	flush    func([]update) error
+	queue    chan update
+	done     chan struct{}
+}
+
+func newBatcher(name string, size int, interval time.Duration, flush func([]update) error) *batcher {
+	if size < 1 {
+		size = 1
+	}
+	b := &batcher{
+		name:     name,
+		size:     size,
+		interval: interval,
+		flush:    flush,
+		queue:    make(chan update, 4*size),
+		done:     make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// publish queues the value of a state
+func (b *batcher) publish(labels prometheus.Labels, value float64, at time.Time) {
+	b.enqueue(update{labels: labels, value: value, at: at})
+}
+
+// publishText queues the text of a text state like publish
+func (b *batcher) publishText(labels prometheus.Labels, text string, at time.Time) {
+	b.enqueue(update{labels: labels, text: text, at: at})
+}
+
+// enqueue queues an update, it is dropped and counted as error if the queue is full
+func (b *batcher) enqueue(u update) {
+	select {
+	case b.queue <- u:
+	default:
+		publishErrors.WithLabelValues(b.name).Inc()
+	}
+}
+
+func (b *batcher) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	batch := make([]update, 0, b.size)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		err := b.flush(batch)
+		if err != nil {
+			publishErrors.WithLabelValues(b.name).Add(float64(len(batch)))
+			log.Warnf("Unable to forward %d updates to %s: %v", len(batch), b.name, err)
+		}
+		batch = make([]update, 0, b.size)
+	}
+
+	for {
+		select {
+		case u, ok := <-b.queue:
+			if !ok {
+				send()
+				return
+			}
+			batch = append(batch, u)
+			if len(batch) >= b.size {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		}
+	}
+}
+
+// close flushes the queued updates and stops the batcher
+func (b *batcher) close() {
+	close(b.queue)
+	<-b.done
+}
@@ -0,0 +1,208 @@
+package sink
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// filter is a compiled filter expression of a webhook, e.g.
+//
+//	type == "SmokeAlarm" && state == "level" && value > 0
+//
+// Identifiers are the labels of the state and value, they are compared with
+// ==, !=, <, <=, >, >=, =~ and !~ to strings and numbers and combined with
+// &&, || and !. Values and labels holding numbers compare as numbers.
+type filter func(labels prometheus.Labels, value float64) bool
+
+// operand is an identifier or a literal of a comparison
+type operand struct {
+	ident   string
+	literal string
+	number  bool
+}
+
+func (o operand) resolve(labels prometheus.Labels, value float64) string {
+	switch {
+	case o.ident == "value":
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case o.ident != "":
+		return labels[o.ident]
+	}
+	return o.literal
+}
+
+// filterParser is a recursive descent parser of filter expressions
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+var filterToken = regexp.MustCompile(`^(\s+|&&|\|\||==|!=|<=|>=|=~|!~|[<>!()]|"(?:[^"\\]|\\.)*"|-?[0-9][0-9.eE+-]*|[A-Za-z_][A-Za-z0-9_]*)`)
+
+// compileFilter compiles a filter expression, the empty expression matches every update
+func compileFilter(expression string) (filter, error) {
+	if strings.TrimSpace(expression) == "" {
+		return func(prometheus.Labels, float64) bool { return true }, nil
+	}
+
+	p := &filterParser{}
+	for rest := expression; rest != ""; {
+		token := filterToken.FindString(rest)
+		if token == "" {
+			return nil, fmt.Errorf("filter %q: unexpected %q", expression, rest)
+		}
+		rest = rest[len(token):]
+		if strings.TrimSpace(token) != "" {
+			p.tokens = append(p.tokens, token)
+		}
+	}
+
+	f, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %v", expression, err)
+	}
+	return f, nil
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *filterParser) or() (filter, error) {
+	left, err := p.and()
+	for err == nil && p.peek() == "||" {
+		p.next()
+		var right filter
+		right, err = p.and()
+		l, r := left, right
+		left = func(labels prometheus.Labels, value float64) bool { return l(labels, value) || r(labels, value) }
+	}
+	return left, err
+}
+
+func (p *filterParser) and() (filter, error) {
+	left, err := p.unary()
+	for err == nil && p.peek() == "&&" {
+		p.next()
+		var right filter
+		right, err = p.unary()
+		l, r := left, right
+		left = func(labels prometheus.Labels, value float64) bool { return l(labels, value) && r(labels, value) }
+	}
+	return left, err
+}
+
+func (p *filterParser) unary() (filter, error) {
+	switch p.peek() {
+	case "!":
+		p.next()
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(labels prometheus.Labels, value float64) bool { return !f(labels, value) }, nil
+	case "(":
+		p.next()
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return f, nil
+	}
+	return p.comparison()
+}
+
+func (p *filterParser) operand() (operand, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return operand{}, fmt.Errorf("unexpected end")
+	case token[0] == '"':
+		literal, err := strconv.Unquote(token)
+		return operand{literal: literal}, err
+	case token[0] == '-' || unicode.IsDigit(rune(token[0])):
+		if _, err := strconv.ParseFloat(token, 64); err != nil {
+			return operand{}, fmt.Errorf("invalid number %q", token)
+		}
+		return operand{literal: token, number: true}, nil
+	case token[0] == '_' || unicode.IsLetter(rune(token[0])):
+		return operand{ident: token}, nil
+	}
+	return operand{}, fmt.Errorf("unexpected %q", token)
+}
+
+func (p *filterParser) comparison() (filter, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "=~", "!~":
+		if right.ident != "" || right.number {
+			return nil, fmt.Errorf("%s needs a regex string", op)
+		}
+		regex, err := regexp.Compile("^(?:" + right.literal + ")$")
+		if err != nil {
+			return nil, err
+		}
+		match := op == "=~"
+		return func(labels prometheus.Labels, value float64) bool {
+			return regex.MatchString(left.resolve(labels, value)) == match
+		}, nil
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+
+	return func(labels prometheus.Labels, value float64) bool {
+		a, b := left.resolve(labels, value), right.resolve(labels, value)
+		x, errA := strconv.ParseFloat(a, 64)
+		y, errB := strconv.ParseFloat(b, 64)
+		if errA == nil && errB == nil {
+			return compare(op, x < y, x == y)
+		}
+		return compare(op, a < b, a == b)
+	}, nil
+}
+
+func compare(op string, less bool, equal bool) bool {
+	switch op {
+	case "==":
+		return equal
+	case "!=":
+		return !equal
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	}
+	return !less
+}
@@ -0,0 +1,100 @@
+package sink
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	graphiteBatchSize = 1000
+	graphiteTimeout   = 10 * time.Second
+)
+
+// graphiteReplacer keeps label values from adding nodes to a metric path
+var graphiteReplacer = strings.NewReplacer(".", "_", " ", "_", "/", "_")
+
+// Graphite sends every state update to Carbon with the plaintext protocol,
+// as <prefix>.<room>.<control>.<state> <value> <timestamp>, states of
+// subcontrols have the subcontrol before the state
+type Graphite struct {
+	cfg     config.GraphiteConfig
+	conn    net.Conn
+	batcher *batcher
+}
+
+// NewGraphite starts sending to Carbon, the connection is opened on the first
+// flush and again after it failed
+func NewGraphite(cfg config.GraphiteConfig) *Graphite {
+	g := &Graphite{cfg: cfg}
+	g.batcher = newBatcher("graphite", graphiteBatchSize, cfg.FlushInterval, g.write)
+	return g
+}
+
+// Publish implements collector.Sink
+func (g *Graphite) Publish(labels prometheus.Labels, value float64, at time.Time) {
+	g.batcher.publish(labels, value, at)
+}
+
+// Close sends the pending updates and closes the connection
+func (g *Graphite) Close() {
+	g.batcher.close()
+	if g.conn != nil {
+		g.conn.Close()
+	}
+}
+
+func graphiteNode(value string) string {
+	if value == "" {
+		return "_"
+	}
+	return graphiteReplacer.Replace(value)
+}
+
+// path is the metric path of a state
+func (g *Graphite) path(labels prometheus.Labels) string {
+	nodes := []string{graphiteNode(labels["room"]), graphiteNode(labels["control"])}
+	if labels["subcontrol"] != "" {
+		nodes = append(nodes, graphiteNode(labels["subcontrol"]))
+	}
+	nodes = append(nodes, graphiteNode(labels["state"]))
+	if g.cfg.Prefix != "" {
+		nodes = append([]string{g.cfg.Prefix}, nodes...)
+	}
+	return strings.Join(nodes, ".")
+}
+
+func (g *Graphite) write(batch []update) error {
+	if g.conn == nil {
+		conn, err := net.DialTimeout("tcp", g.cfg.Address, graphiteTimeout)
+		if err != nil {
+			return err
+		}
+		g.conn = conn
+	}
+
+	w := bufio.NewWriter(g.conn)
+	for _, u := range batch {
+		w.WriteString(g.path(u.labels))
+		w.WriteByte(' ')
+		w.WriteString(strconv.FormatFloat(u.value, 'f', -1, 64))
+		w.WriteByte(' ')
+		w.WriteString(strconv.FormatInt(u.at.Unix(), 10))
+		w.WriteByte('\n')
+	}
+
+	g.conn.SetWriteDeadline(time.Now().Add(graphiteTimeout))
+	err := w.Flush()
+	if err != nil {
+		// Reconnect on the next flush, Carbon may have restarted
+		g.conn.Close()
+		g.conn = nil
+	}
+	return err
+}
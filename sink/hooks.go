@@ -0,0 +1,184 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"github.com/XciD/loxone-prometheus-exporter/collector"
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// connectionEvents are the events a connection hook can be called on
+var connectionEvents = []string{collector.EventConnect, collector.EventDisconnect, collector.EventAuthFailure}
+
+// ConnectionHook calls a URL or runs a command on the connection events of
+// the Miniservers
+type ConnectionHook struct {
+	cfg      config.ConnectionHookConfig
+	events   map[string]bool
+	template *template.Template
+	client   *http.Client
+	queue    chan collector.ConnectionEvent
+	done     chan struct{}
+}
+
+// NewConnectionHook compiles the template of the hook and starts calling it
+func NewConnectionHook(cfg config.ConnectionHookConfig) (*ConnectionHook, error) {
+	err := validateConnectionHook(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var tmpl *template.Template
+	if cfg.Template != "" {
+		tmpl, err = template.New("connection-hook").Funcs(template.FuncMap{"json": toJSON}).Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("connection hook template: %v", err)
+		}
+	}
+
+	events := make(map[string]bool)
+	for _, event := range cfg.Events {
+		events[event] = true
+	}
+	if len(events) == 0 {
+		for _, event := range connectionEvents {
+			events[event] = true
+		}
+	}
+
+	h := &ConnectionHook{
+		cfg:      cfg,
+		events:   events,
+		template: tmpl,
+		client:   &http.Client{Timeout: webhookTimeout},
+		queue:    make(chan collector.ConnectionEvent, webhookQueueSize),
+		done:     make(chan struct{}),
+	}
+	go h.run()
+	return h, nil
+}
+
+// validateConnectionHook checks the hook has a URL or a command and knows its events
+func validateConnectionHook(cfg config.ConnectionHookConfig) error {
+	if (cfg.URL == "") == (len(cfg.Command) == 0) {
+		return fmt.Errorf("either url or command must be set")
+	}
+	for _, event := range cfg.Events {
+		known := false
+		for _, connectionEvent := range connectionEvents {
+			known = known || event == connectionEvent
+		}
+		if !known {
+			return fmt.Errorf("unknown event %q, use %s", event, strings.Join(connectionEvents, ", "))
+		}
+	}
+	return nil
+}
+
+// ValidateConnectionHooks checks the targets, events and templates of the connection hooks
+func ValidateConnectionHooks(hooks []config.ConnectionHookConfig) []error {
+	errs := make([]error, 0)
+	for i, cfg := range hooks {
+		if err := validateConnectionHook(cfg); err != nil {
+			errs = append(errs, fmt.Errorf("connection-hooks[%d]: %v", i, err))
+		}
+		if _, err := template.New("connection-hook").Funcs(template.FuncMap{"json": toJSON}).Parse(cfg.Template); err != nil {
+			errs = append(errs, fmt.Errorf("connection-hooks[%d]: template: %v", i, err))
+		}
+	}
+	return errs
+}
+
+// Notify implements collector.ConnectionHook
+func (h *ConnectionHook) Notify(event collector.ConnectionEvent) {
+	if !h.events[event.Event] {
+		return
+	}
+	select {
+	case h.queue <- event:
+	default:
+		publishErrors.WithLabelValues("connection-hook").Inc()
+	}
+}
+
+// Close calls the hook for the queued events and stops
+func (h *ConnectionHook) Close() {
+	close(h.queue)
+	<-h.done
+}
+
+func (h *ConnectionHook) run() {
+	defer close(h.done)
+	for event := range h.queue {
+		err := h.call(event)
+		if err != nil {
+			publishErrors.WithLabelValues("connection-hook").Inc()
+			log.Warnf("Connection hook for %s of %s failed: %v", event.Event, event.Miniserver, err)
+		}
+	}
+}
+
+func (h *ConnectionHook) call(event collector.ConnectionEvent) error {
+	var payload bytes.Buffer
+	if h.template != nil {
+		err := h.template.Execute(&payload, event)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := json.NewEncoder(&payload).Encode(event)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(h.cfg.Command) > 0 {
+		return h.runCommand(event, &payload)
+	}
+
+	method := h.cfg.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(strings.ToUpper(method), h.cfg.URL, &payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, header := range h.cfg.Headers {
+		parts := strings.SplitN(header, "=", 2)
+		if len(parts) == 2 {
+			req.Header.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		}
+	}
+	return send(h.client, req)
+}
+
+// runCommand runs the command with the payload on stdin and the event in
+// its environment, it's killed after the webhook timeout
+func (h *ConnectionHook) runCommand(event collector.ConnectionEvent, payload *bytes.Buffer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.cfg.Command[0], h.cfg.Command[1:]...)
+	cmd.Stdin = payload
+	cmd.Env = append(os.Environ(),
+		"LOXONE_HOOK_EVENT="+event.Event,
+		"LOXONE_HOOK_MINISERVER="+event.Miniserver,
+		"LOXONE_HOOK_ERROR="+event.Error,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const influxTimeout = 30 * time.Second
+
+// Influx writes every state update to an InfluxDB v2 bucket with the line
+// protocol, the labels become tags and the value the value field
+type Influx struct {
+	cfg     config.InfluxConfig
+	client  *http.Client
+	batcher *batcher
+}
+
+// NewInflux starts writing to the bucket
+func NewInflux(cfg config.InfluxConfig) *Influx {
+	i := &Influx{cfg: cfg, client: &http.Client{Timeout: influxTimeout}}
+	i.batcher = newBatcher("influx", cfg.BatchSize, cfg.FlushInterval, i.write)
+	return i
+}
+
+// Publish implements collector.Sink
+func (i *Influx) Publish(labels prometheus.Labels, value float64, at time.Time) {
+	i.batcher.publish(labels, value, at)
+}
+
+// Close writes the pending updates
+func (i *Influx) Close() {
+	i.batcher.close()
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+)
+
+// line formats an update in the line protocol, tags with empty values are left out
+func (i *Influx) line(u update) string {
+	names := make([]string, 0, len(u.labels))
+	for name, value := range u.labels {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var line strings.Builder
+	line.WriteString(influxMeasurementEscaper.Replace(i.cfg.Measurement))
+	for _, name := range names {
+		fmt.Fprintf(&line, ",%s=%s", influxTagEscaper.Replace(name), influxTagEscaper.Replace(u.labels[name]))
+	}
+	fmt.Fprintf(&line, " value=%s %d", strconv.FormatFloat(u.value, 'g', -1, 64), u.at.UnixNano())
+	return line.String()
+}
+
+func (i *Influx) write(batch []update) error {
+	var body bytes.Buffer
+	for _, u := range batch {
+		body.WriteString(i.line(u))
+		body.WriteByte('\n')
+	}
+
+	query := url.Values{"org": {i.cfg.Org}, "bucket": {i.cfg.Bucket}, "precision": {"ns"}}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(i.cfg.URL, "/")+"/api/v2/write?"+query.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if i.cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+i.cfg.Token)
+	}
+
+	return send(i.client, req)
+}
@@ -0,0 +1,102 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const lokiTimeout = 30 * time.Second
+
+// lokiLabels are the labels of the Loki streams, the other labels of the
+// states would make too many streams
+var lokiLabels = []string{"miniserver", "control", "room", "cat"}
+
+// Loki pushes the texts of text states, e.g. tracker entries and alarm
+// texts, to Loki as log lines
+type Loki struct {
+	cfg     config.LokiConfig
+	client  *http.Client
+	batcher *batcher
+}
+
+// NewLoki starts pushing to Loki
+func NewLoki(cfg config.LokiConfig) *Loki {
+	l := &Loki{cfg: cfg, client: &http.Client{Timeout: lokiTimeout}}
+	l.batcher = newBatcher("loki", cfg.BatchSize, cfg.FlushInterval, l.write)
+	return l
+}
+
+// PublishText implements collector.TextSink
+func (l *Loki) PublishText(labels prometheus.Labels, text string, at time.Time) {
+	l.batcher.publishText(labels, text, at)
+}
+
+// Close pushes the pending texts
+func (l *Loki) Close() {
+	l.batcher.close()
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type lokiPush struct {
+	Streams []*lokiStream `json:"streams"`
+}
+
+// push groups the texts by stream, the state is part of the line since a
+// control can have several text states
+func (l *Loki) push(batch []update) *lokiPush {
+	push := &lokiPush{Streams: make([]*lokiStream, 0)}
+	streams := make(map[string]*lokiStream)
+	for _, u := range batch {
+		labels := make(map[string]string, len(lokiLabels))
+		values := make([]string, 0, len(lokiLabels))
+		for _, name := range lokiLabels {
+			if value := u.labels[name]; value != "" {
+				labels[name] = value
+			}
+			values = append(values, u.labels[name])
+		}
+		key := strings.Join(values, "\x00")
+		stream, ok := streams[key]
+		if !ok {
+			stream = &lokiStream{Stream: labels, Values: make([][2]string, 0)}
+			streams[key] = stream
+			push.Streams = append(push.Streams, stream)
+		}
+		line := u.labels["state"] + ": " + u.text
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(u.at.UnixNano(), 10), line})
+	}
+	return push
+}
+
+func (l *Loki) write(batch []update) error {
+	body, err := json.Marshal(l.push(batch))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(l.cfg.URL, "/")+"/loki/api/v1/push", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if l.cfg.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", l.cfg.TenantID)
+	}
+	if l.cfg.User != "" {
+		req.SetBasicAuth(l.cfg.User, l.cfg.Password)
+	}
+
+	return send(l.client, req)
+}
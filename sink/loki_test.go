@@ -0,0 +1,52 @@
+package sink
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestLokiPushesTexts(t *testing.T) {
+	pushes := make(chan *http.Request, 1)
+	bodies := make(chan lokiPush, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var push lokiPush
+		if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+			t.Error(err)
+		}
+		pushes <- r
+		bodies <- push
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	loki := NewLoki(config.LokiConfig{URL: server.URL, TenantID: "home", BatchSize: 10, FlushInterval: time.Hour})
+	labels := prometheus.Labels{"miniserver": "home", "control": "Alarm", "room": "Hall", "cat": "", "type": "Tracker", "state": "entries"}
+	at := time.Unix(1700000000, 0)
+	loki.PublishText(labels, "Door opened", at)
+	loki.PublishText(labels, "Door closed", at.Add(time.Second))
+	loki.Close()
+
+	req := <-pushes
+	if req.URL.Path != "/loki/api/v1/push" || req.Header.Get("X-Scope-OrgID") != "home" {
+		t.Errorf("pushed to %s with tenant %q", req.URL.Path, req.Header.Get("X-Scope-OrgID"))
+	}
+	push := <-bodies
+	want := []*lokiStream{{
+		Stream: map[string]string{"miniserver": "home", "control": "Alarm", "room": "Hall"},
+		Values: [][2]string{
+			{"1700000000000000000", "entries: Door opened"},
+			{"1700000001000000000", "entries: Door closed"},
+		},
+	}}
+	if !reflect.DeepEqual(push.Streams, want) {
+		t.Errorf("streams %+v, want %+v", push.Streams, want)
+	}
+}
@@ -0,0 +1,70 @@
+package sink
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const mqttDisconnectTimeout = 250 // milliseconds
+
+// MQTT publishes every state update to <prefix>/<room>/<control>/<state>,
+// states of subcontrols have the subcontrol before the state
+type MQTT struct {
+	client mqtt.Client
+	cfg    config.MQTTConfig
+}
+
+// NewMQTT connects to the broker, connecting is retried in the background
+// until the broker is reachable
+func NewMQTT(cfg config.MQTTConfig) *MQTT {
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.User).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(10 * time.Second).
+		SetOnConnectHandler(func(mqtt.Client) {
+			log.Infof("Connected to MQTT broker %s", cfg.Broker)
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Warnf("Connection to MQTT broker %s lost: %v", cfg.Broker, err)
+		})
+
+	client := mqtt.NewClient(opts)
+	client.Connect()
+	return &MQTT{client: client, cfg: cfg}
+}
+
+// Publish implements collector.Sink
+func (m *MQTT) Publish(labels prometheus.Labels, value float64, at time.Time) {
+	levels := []string{m.cfg.TopicPrefix, segment(labels["room"]), segment(labels["control"])}
+	if labels["subcontrol"] != "" {
+		levels = append(levels, segment(labels["subcontrol"]))
+	}
+	topic := strings.Join(append(levels, segment(labels["state"])), "/")
+	token := m.client.Publish(topic, byte(m.cfg.QoS), m.cfg.Retain, strconv.FormatFloat(value, 'f', -1, 64))
+
+	// Don't wait for the broker, only count what already failed
+	select {
+	case <-token.Done():
+		if token.Error() != nil {
+			publishErrors.WithLabelValues("mqtt").Inc()
+			log.Debugf("Unable to publish %s: %v", topic, token.Error())
+		}
+	default:
+	}
+}
+
+// Close disconnects from the broker
+func (m *MQTT) Close() {
+	m.client.Disconnect(mqttDisconnectTimeout)
+}
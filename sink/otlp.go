@@ -0,0 +1,275 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/version"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// cumulative is AGGREGATION_TEMPORALITY_CUMULATIVE, as in Prometheus counters never restart on push
+const cumulative = 2
+
+// OTLP pushes the gathered metrics to an OpenTelemetry Collector with OTLP
+// over HTTP, gauges become gauges, counters monotonic sums, histograms and
+// summaries the same in OTel
+type OTLP struct {
+	cfg      config.OTLPConfig
+	url      string
+	headers  http.Header
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	// start is the start time of the cumulative metrics without a created timestamp
+	start time.Time
+}
+
+// NewOTLP pushes what the gatherer gathers to the configured endpoint, a
+// base URL gets the /v1/metrics path of OTLP/HTTP
+func NewOTLP(cfg config.OTLPConfig, gatherer prometheus.Gatherer) (*OTLP, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = "/v1/metrics"
+	}
+
+	headers := http.Header{}
+	for _, header := range cfg.Headers {
+		parts := strings.SplitN(header, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("otlp header %q isn't name=value", header)
+		}
+		headers.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+
+	return &OTLP{
+		cfg:      cfg,
+		url:      endpoint.String(),
+		headers:  headers,
+		gatherer: gatherer,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		start:    time.Now(),
+	}, nil
+}
+
+// Run pushes the metrics every interval until the context is done
+func (o *OTLP) Run(ctx context.Context) {
+	pushEvery(ctx, o.cfg.Interval, "otlp", o.url, o.push)
+}
+
+func (o *OTLP) push(now time.Time) error {
+	families, err := o.gatherer.Gather()
+	if err != nil {
+		log.Debugf("Gathering the metrics to push failed partly: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, o.url, bytes.NewReader(o.encode(families, now)))
+	if err != nil {
+		return err
+	}
+	for name, values := range o.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	return send(o.client, req)
+}
+
+// encode encodes the families as an ExportMetricsServiceRequest of
+// opentelemetry-proto, with a single resource and scope:
+//
+//	ExportMetricsServiceRequest { repeated ResourceMetrics resource_metrics = 1; }
+//	ResourceMetrics { Resource resource = 1; repeated ScopeMetrics scope_metrics = 2; }
+//	ScopeMetrics    { InstrumentationScope scope = 1; repeated Metric metrics = 2; }
+//	Metric          { string name = 1; string description = 2; oneof data: Gauge gauge = 5,
+//	                  Sum sum = 7, Histogram histogram = 9, Summary summary = 11; }
+func (o *OTLP) encode(families []*dto.MetricFamily, now time.Time) []byte {
+	var resource []byte
+	resource = appendMessage(resource, 1, stringAttribute("service.name", "loxone-exporter"))
+	if version.Version != "" {
+		resource = appendMessage(resource, 1, stringAttribute("service.version", version.Version))
+	}
+
+	var scope []byte
+	scope = appendString(scope, 1, "github.com/XciD/loxone-prometheus-exporter")
+
+	var scopeMetrics []byte
+	scopeMetrics = appendMessage(scopeMetrics, 1, scope)
+	for _, family := range families {
+		if metric := o.encodeFamily(family, now); metric != nil {
+			scopeMetrics = appendMessage(scopeMetrics, 2, metric)
+		}
+	}
+
+	var resourceMetrics []byte
+	resourceMetrics = appendMessage(resourceMetrics, 1, resource)
+	resourceMetrics = appendMessage(resourceMetrics, 2, scopeMetrics)
+
+	return appendMessage(nil, 1, resourceMetrics)
+}
+
+func (o *OTLP) encodeFamily(family *dto.MetricFamily, now time.Time) []byte {
+	var data []byte
+	var field protowire.Number
+	for _, metric := range family.Metric {
+		var point []byte
+		switch family.GetType() {
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			value := metric.GetGauge().GetValue()
+			if family.GetType() == dto.MetricType_UNTYPED {
+				value = metric.GetUntyped().GetValue()
+			}
+			field = 5
+			point = o.numberPoint(metric, time.Time{}, now, value)
+		case dto.MetricType_COUNTER:
+			field = 7
+			point = o.numberPoint(metric, o.startTime(metric.GetCounter().GetCreatedTimestamp().AsTime()), now, metric.GetCounter().GetValue())
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+			field = 9
+			point = o.histogramPoint(metric, now)
+		case dto.MetricType_SUMMARY:
+			field = 11
+			point = o.summaryPoint(metric, now)
+		default:
+			continue
+		}
+		data = appendMessage(data, 1, point)
+	}
+	if data == nil {
+		return nil
+	}
+	// Sums and histograms are cumulative, sums of counters monotonic
+	switch field {
+	case 7:
+		data = protowire.AppendTag(data, 2, protowire.VarintType)
+		data = protowire.AppendVarint(data, cumulative)
+		data = protowire.AppendTag(data, 3, protowire.VarintType)
+		data = protowire.AppendVarint(data, 1)
+	case 9:
+		data = protowire.AppendTag(data, 2, protowire.VarintType)
+		data = protowire.AppendVarint(data, cumulative)
+	}
+
+	var metric []byte
+	metric = appendString(metric, 1, family.GetName())
+	metric = appendString(metric, 2, family.GetHelp())
+	return appendMessage(metric, field, data)
+}
+
+// startTime is the created timestamp of a cumulative metric if it has one
+func (o *OTLP) startTime(created time.Time) time.Time {
+	if created.Unix() > 0 {
+		return created
+	}
+	return o.start
+}
+
+// numberPoint is a NumberDataPoint { repeated KeyValue attributes = 7;
+// fixed64 start_time_unix_nano = 2; fixed64 time_unix_nano = 3; double as_double = 4; }
+func (o *OTLP) numberPoint(metric *dto.Metric, start time.Time, now time.Time, value float64) []byte {
+	point := appendAttributes(nil, 7, metric)
+	point = appendTimes(point, start, now)
+	point = protowire.AppendTag(point, 4, protowire.Fixed64Type)
+	return protowire.AppendFixed64(point, math.Float64bits(value))
+}
+
+// histogramPoint is a HistogramDataPoint { repeated KeyValue attributes = 9; fixed64 start_time_unix_nano = 2;
+// fixed64 time_unix_nano = 3; fixed64 count = 4; double sum = 5; repeated fixed64 bucket_counts = 6;
+// repeated double explicit_bounds = 7; }, the buckets aren't cumulative in OTel
+func (o *OTLP) histogramPoint(metric *dto.Metric, now time.Time) []byte {
+	histogram := metric.GetHistogram()
+	point := appendAttributes(nil, 9, metric)
+	point = appendTimes(point, o.startTime(histogram.GetCreatedTimestamp().AsTime()), now)
+	point = protowire.AppendTag(point, 4, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, histogram.GetSampleCount())
+	point = protowire.AppendTag(point, 5, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, math.Float64bits(histogram.GetSampleSum()))
+
+	var counts, bounds []byte
+	previous := uint64(0)
+	for _, bucket := range histogram.Bucket {
+		if math.IsInf(bucket.GetUpperBound(), 1) {
+			continue
+		}
+		counts = protowire.AppendFixed64(counts, bucket.GetCumulativeCount()-previous)
+		bounds = protowire.AppendFixed64(bounds, math.Float64bits(bucket.GetUpperBound()))
+		previous = bucket.GetCumulativeCount()
+	}
+	counts = protowire.AppendFixed64(counts, histogram.GetSampleCount()-previous)
+	point = protowire.AppendTag(point, 6, protowire.BytesType)
+	point = protowire.AppendBytes(point, counts)
+	if bounds != nil {
+		point = protowire.AppendTag(point, 7, protowire.BytesType)
+		point = protowire.AppendBytes(point, bounds)
+	}
+	return point
+}
+
+// summaryPoint is a SummaryDataPoint { repeated KeyValue attributes = 7; fixed64 start_time_unix_nano = 2;
+// fixed64 time_unix_nano = 3; fixed64 count = 4; double sum = 5;
+// repeated ValueAtQuantile quantile_values = 6 { double quantile = 1; double value = 2; }; }
+func (o *OTLP) summaryPoint(metric *dto.Metric, now time.Time) []byte {
+	summary := metric.GetSummary()
+	point := appendAttributes(nil, 7, metric)
+	point = appendTimes(point, o.startTime(summary.GetCreatedTimestamp().AsTime()), now)
+	point = protowire.AppendTag(point, 4, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, summary.GetSampleCount())
+	point = protowire.AppendTag(point, 5, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, math.Float64bits(summary.GetSampleSum()))
+	for _, quantile := range summary.Quantile {
+		var q []byte
+		q = protowire.AppendTag(q, 1, protowire.Fixed64Type)
+		q = protowire.AppendFixed64(q, math.Float64bits(quantile.GetQuantile()))
+		q = protowire.AppendTag(q, 2, protowire.Fixed64Type)
+		q = protowire.AppendFixed64(q, math.Float64bits(quantile.GetValue()))
+		point = appendMessage(point, 6, q)
+	}
+	return point
+}
+
+// appendAttributes adds the labels of the metric as attributes, empty ones are left out
+func appendAttributes(b []byte, field protowire.Number, metric *dto.Metric) []byte {
+	for _, label := range metric.Label {
+		if label.GetValue() != "" {
+			b = appendMessage(b, field, stringAttribute(label.GetName(), label.GetValue()))
+		}
+	}
+	return b
+}
+
+// appendTimes adds the start time, if any, and the time of a data point
+func appendTimes(b []byte, start time.Time, now time.Time) []byte {
+	if !start.IsZero() {
+		b = protowire.AppendTag(b, 2, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, uint64(start.UnixNano()))
+	}
+	b = protowire.AppendTag(b, 3, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, uint64(now.UnixNano()))
+}
+
+// stringAttribute is a KeyValue { string key = 1; AnyValue value = 2 { string string_value = 1; }; }
+func stringAttribute(key string, value string) []byte {
+	return appendMessage(appendString(nil, 1, key), 2, appendString(nil, 1, value))
+}
+
+func appendString(b []byte, field protowire.Number, value string) []byte {
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+func appendMessage(b []byte, field protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
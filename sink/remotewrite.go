@@ -0,0 +1,161 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// RemoteWrite pushes the gathered metrics with the Prometheus remote_write
+// protocol, for exporters Prometheus can't scrape, e.g. behind NAT
+type RemoteWrite struct {
+	cfg      config.RemoteWriteConfig
+	gatherer prometheus.Gatherer
+	client   *http.Client
+}
+
+// NewRemoteWrite pushes what the gatherer gathers to the configured URL
+func NewRemoteWrite(cfg config.RemoteWriteConfig, gatherer prometheus.Gatherer) *RemoteWrite {
+	return &RemoteWrite{cfg: cfg, gatherer: gatherer, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+// Run pushes the metrics every interval until the context is done
+func (r *RemoteWrite) Run(ctx context.Context) {
+	pushEvery(ctx, r.cfg.Interval, "remote_write", r.cfg.URL, r.push)
+}
+
+func (r *RemoteWrite) push(now time.Time) error {
+	families, err := r.gatherer.Gather()
+	if err != nil {
+		log.Debugf("Gathering the metrics to push failed partly: %v", err)
+	}
+	request := encodeWriteRequest(families, now.UnixNano()/int64(time.Millisecond))
+
+	req, err := http.NewRequest(http.MethodPost, r.cfg.URL, bytes.NewReader(snappy.Encode(nil, request)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if r.cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+r.cfg.BearerToken)
+	} else if r.cfg.User != "" {
+		req.SetBasicAuth(r.cfg.User, r.cfg.Password)
+	}
+
+	return send(r.client, req)
+}
+
+// series is a sample with its labels, __name__ included, and its
+// timestamp in milliseconds if the metric has one
+type series struct {
+	labels    []*dto.LabelPair
+	value     float64
+	timestamp int64
+}
+
+// familySeries flattens a metric family to samples the way the text format
+// does, summaries and histograms get their _sum, _count and quantile or bucket series
+func familySeries(family *dto.MetricFamily) []series {
+	var result []series
+	add := func(metric *dto.Metric, name string, value float64, extra ...*dto.LabelPair) {
+		labels := make([]*dto.LabelPair, 0, len(metric.Label)+len(extra)+1)
+		labels = append(labels, &dto.LabelPair{Name: stringPtr("__name__"), Value: stringPtr(name)})
+		for _, pair := range append(metric.Label, extra...) {
+			// empty labels are the same as missing ones, remote_write doesn't take them
+			if pair.GetValue() != "" {
+				labels = append(labels, pair)
+			}
+		}
+		sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+		result = append(result, series{labels: labels, value: value, timestamp: metric.GetTimestampMs()})
+	}
+	label := func(name string, value float64) *dto.LabelPair {
+		return &dto.LabelPair{Name: stringPtr(name), Value: stringPtr(strconv.FormatFloat(value, 'g', -1, 64))}
+	}
+
+	name := family.GetName()
+	for _, metric := range family.Metric {
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			add(metric, name, metric.GetCounter().GetValue())
+		case dto.MetricType_GAUGE:
+			add(metric, name, metric.GetGauge().GetValue())
+		case dto.MetricType_UNTYPED:
+			add(metric, name, metric.GetUntyped().GetValue())
+		case dto.MetricType_SUMMARY:
+			summary := metric.GetSummary()
+			for _, quantile := range summary.Quantile {
+				add(metric, name, quantile.GetValue(), label("quantile", quantile.GetQuantile()))
+			}
+			add(metric, name+"_sum", summary.GetSampleSum())
+			add(metric, name+"_count", float64(summary.GetSampleCount()))
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+			histogram := metric.GetHistogram()
+			for _, bucket := range histogram.Bucket {
+				add(metric, name+"_bucket", float64(bucket.GetCumulativeCount()), label("le", bucket.GetUpperBound()))
+			}
+			add(metric, name+"_bucket", float64(histogram.GetSampleCount()), label("le", math.Inf(1)))
+			add(metric, name+"_sum", histogram.GetSampleSum())
+			add(metric, name+"_count", float64(histogram.GetSampleCount()))
+		}
+	}
+	return result
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// encodeWriteRequest encodes the families as a prometheus.WriteRequest protobuf,
+// every sample with its own timestamp or the given one, in milliseconds:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(families []*dto.MetricFamily, timestamp int64) []byte {
+	var request []byte
+	for _, family := range families {
+		for _, s := range familySeries(family) {
+			var ts []byte
+			for _, label := range s.labels {
+				var l []byte
+				l = protowire.AppendTag(l, 1, protowire.BytesType)
+				l = protowire.AppendString(l, label.GetName())
+				l = protowire.AppendTag(l, 2, protowire.BytesType)
+				l = protowire.AppendString(l, label.GetValue())
+				ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+				ts = protowire.AppendBytes(ts, l)
+			}
+			var sample []byte
+			sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+			sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+			if s.timestamp != 0 {
+				sample = protowire.AppendVarint(sample, uint64(s.timestamp))
+			} else {
+				sample = protowire.AppendVarint(sample, uint64(timestamp))
+			}
+			ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, sample)
+
+			request = protowire.AppendTag(request, 1, protowire.BytesType)
+			request = protowire.AppendBytes(request, ts)
+		}
+	}
+	return request
+}
@@ -0,0 +1,73 @@
+// Package sink forwards the state updates of the collector to other systems
+// than Prometheus
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var publishErrors = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "loxone_sink_errors_total",
+		Help: "Number of state updates, or pushes of remote_write, a sink failed to forward",
+	},
+	[]string{"sink"},
+)
+
+// RegisterMetrics registers the metrics of the sinks
+func RegisterMetrics() {
+	prometheus.MustRegister(publishErrors)
+}
+
+// segmentReplacer keeps label values from adding levels or wildcards to a topic or path
+var segmentReplacer = strings.NewReplacer("/", "_", "+", "_", "#", "_", " ", "_")
+
+func segment(value string) string {
+	if value == "" {
+		return "_"
+	}
+	return segmentReplacer.Replace(value)
+}
+
+// send sends an HTTP request, answers other than 2xx are errors with the start of their body
+func send(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// pushEvery calls push every interval until the context is done, failures
+// are counted for the sink and logged
+func pushEvery(ctx context.Context, interval time.Duration, sink string, target string, push func(time.Time) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := push(time.Now())
+			if err != nil {
+				publishErrors.WithLabelValues(sink).Inc()
+				log.Warnf("Unable to push the metrics to %s: %v", target, err)
+			}
+		}
+	}
+}
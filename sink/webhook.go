@@ -0,0 +1,186 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/XciD/loxone-prometheus-exporter/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	webhookQueueSize = 100
+	webhookTimeout   = 10 * time.Second
+)
+
+// WebhookEvent is what the payload template of a webhook is executed with
+type WebhookEvent struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+	Time   time.Time         `json:"time"`
+}
+
+// Webhook calls a URL when a state update matches its filter, only when the
+// filter starts matching, not again for the following updates that match too
+type Webhook struct {
+	cfg      config.WebhookConfig
+	filter   filter
+	template *template.Template
+	client   *http.Client
+	queue    chan WebhookEvent
+	done     chan struct{}
+
+	sync.Mutex
+	// matching are the states matching the filter on their last update
+	matching map[string]bool
+}
+
+// NewWebhook compiles the filter and template of the webhook and starts calling it
+func NewWebhook(cfg config.WebhookConfig) (*Webhook, error) {
+	f, err := compileFilter(cfg.Filter)
+	if err != nil {
+		return nil, err
+	}
+	var tmpl *template.Template
+	if cfg.Template != "" {
+		tmpl, err = template.New("webhook").Funcs(template.FuncMap{"json": toJSON}).Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("webhook template: %v", err)
+		}
+	}
+
+	w := &Webhook{
+		cfg:      cfg,
+		filter:   f,
+		template: tmpl,
+		client:   &http.Client{Timeout: webhookTimeout},
+		queue:    make(chan WebhookEvent, webhookQueueSize),
+		done:     make(chan struct{}),
+		matching: make(map[string]bool),
+	}
+	go w.run()
+	return w, nil
+}
+
+// ValidateWebhooks compiles the filters and templates of the webhooks
+func ValidateWebhooks(webhooks []config.WebhookConfig) []error {
+	errs := make([]error, 0)
+	for i, cfg := range webhooks {
+		if cfg.URL == "" {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: url is missing", i))
+		}
+		if _, err := compileFilter(cfg.Filter); err != nil {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: %v", i, err))
+		}
+		if _, err := template.New("webhook").Funcs(template.FuncMap{"json": toJSON}).Parse(cfg.Template); err != nil {
+			errs = append(errs, fmt.Errorf("webhooks[%d]: template: %v", i, err))
+		}
+	}
+	return errs
+}
+
+func toJSON(value interface{}) (string, error) {
+	b, err := json.Marshal(value)
+	return string(b), err
+}
+
+// stateKey identifies a state by its labels
+func stateKey(labels prometheus.Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var key strings.Builder
+	for _, name := range names {
+		key.WriteString(labels[name])
+		key.WriteByte(0)
+	}
+	return key.String()
+}
+
+// Publish implements collector.Sink
+func (w *Webhook) Publish(labels prometheus.Labels, value float64, at time.Time) {
+	matches := w.filter(labels, value)
+	key := stateKey(labels)
+
+	w.Lock()
+	matched := w.matching[key]
+	if matches {
+		w.matching[key] = true
+	} else {
+		delete(w.matching, key)
+	}
+	w.Unlock()
+	if !matches || matched {
+		return
+	}
+
+	event := WebhookEvent{Labels: make(map[string]string, len(labels)), Value: value, Time: at}
+	for name, value := range labels {
+		event.Labels[name] = value
+	}
+	select {
+	case w.queue <- event:
+	default:
+		publishErrors.WithLabelValues("webhook").Inc()
+	}
+}
+
+// Close calls the webhook for the queued events and stops
+func (w *Webhook) Close() {
+	close(w.queue)
+	<-w.done
+}
+
+func (w *Webhook) run() {
+	defer close(w.done)
+	for event := range w.queue {
+		err := w.call(event)
+		if err != nil {
+			publishErrors.WithLabelValues("webhook").Inc()
+			log.Warnf("Webhook %s failed: %v", w.cfg.URL, err)
+		}
+	}
+}
+
+func (w *Webhook) call(event WebhookEvent) error {
+	var body bytes.Buffer
+	if w.template != nil {
+		err := w.template.Execute(&body, event)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := json.NewEncoder(&body).Encode(event)
+		if err != nil {
+			return err
+		}
+	}
+
+	method := w.cfg.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(strings.ToUpper(method), w.cfg.URL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, header := range w.cfg.Headers {
+		parts := strings.SplitN(header, "=", 2)
+		if len(parts) == 2 {
+			req.Header.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		}
+	}
+	return send(w.client, req)
+}
@@ -0,0 +1,315 @@
+package events
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Event represent a Loxone EventTypeEvent with an UUID and a Value, an
+// EventTypeEventtext with an UUID and a Text, an EventTypeDaytimer with an
+// UUID and a Daytimer or an EventTypeWeather with the UUID of the weather
+// server and its Weather
+type Event struct {
+	UUID  string
+	Value float64
+	// Text is only set for text events
+	Text *Text
+	// Daytimer is only set for daytimer events
+	Daytimer *Daytimer
+	// Weather is only set for weather events
+	Weather *Weather
+}
+
+// Text is the value of a text event, with the UUID of its icon
+type Text struct {
+	Icon  string
+	Value string
+}
+
+type BinaryEvent struct {
+	EventType EventType
+	Events    []*Event
+	Data      *[]byte
+}
+
+type Header struct {
+	EventType EventType
+	Length    int
+	Estimated bool
+	Empty     bool
+}
+
+var EmptyHeader = &Header{Empty: true}
+
+type EventType int
+
+const (
+	EventTypeText         EventType = 0
+	EventTypeFile         EventType = 1
+	EventTypeEvent        EventType = 2
+	EventTypeEventtext    EventType = 3
+	EventTypeDaytimer     EventType = 4
+	EventTypeOutofservice EventType = 5
+	EventTypeKeepalive    EventType = 6
+	EventTypeWeather      EventType = 7
+)
+
+// readEventText reads text events: the UUID of the state, the UUID of its
+// icon, the length of the text and the text, padded to a multiple of 4 bytes
+func (e *BinaryEvent) readEventText(dataRef *[]byte) {
+	data := *dataRef
+	events := make([]*Event, 0)
+	for len(data) >= 36 {
+		length := int(binary.LittleEndian.Uint32(data[32:36]))
+		if length > len(data)-36 {
+			break
+		}
+		events = append(events, &Event{
+			UUID: readUUID(data[0:16]),
+			Text: &Text{
+				Icon:  readUUID(data[16:32]),
+				Value: string(data[36 : 36+length]),
+			},
+		})
+
+		next := 36 + length
+		if padding := length % 4; padding != 0 {
+			next += 4 - padding
+		}
+		if next > len(data) {
+			break
+		}
+		data = data[next:]
+	}
+
+	e.Events = events
+}
+
+// Daytimer is the schedule of a daytimer, its value outside of the entries
+// and the entries of every operating mode
+type Daytimer struct {
+	DefaultValue float64
+	Entries      []DaytimerEntry
+}
+
+// DaytimerEntry sets the value of a daytimer on the days of an operating mode,
+// from and to are in minutes after midnight
+type DaytimerEntry struct {
+	Mode         int32
+	From         int32
+	To           int32
+	NeedActivate int32
+	Value        float64
+}
+
+const (
+	daytimerHeaderLength = 28
+	daytimerEntryLength  = 24
+)
+
+// readDaytimer reads daytimer events: the UUID of the state, the default
+// value, the number of entries and the entries
+func (e *BinaryEvent) readDaytimer(dataRef *[]byte) {
+	data := *dataRef
+	events := make([]*Event, 0)
+	for len(data) >= daytimerHeaderLength {
+		count := int(binary.LittleEndian.Uint32(data[24:28]))
+		if count > (len(data)-daytimerHeaderLength)/daytimerEntryLength {
+			break
+		}
+		daytimer := &Daytimer{
+			DefaultValue: math.Float64frombits(binary.LittleEndian.Uint64(data[16:24])),
+			Entries:      make([]DaytimerEntry, 0, count),
+		}
+		for i := 0; i < count; i++ {
+			entry := data[daytimerHeaderLength+i*daytimerEntryLength:]
+			daytimer.Entries = append(daytimer.Entries, DaytimerEntry{
+				Mode:         int32(binary.LittleEndian.Uint32(entry[0:4])),
+				From:         int32(binary.LittleEndian.Uint32(entry[4:8])),
+				To:           int32(binary.LittleEndian.Uint32(entry[8:12])),
+				NeedActivate: int32(binary.LittleEndian.Uint32(entry[12:16])),
+				Value:        math.Float64frombits(binary.LittleEndian.Uint64(entry[16:24])),
+			})
+		}
+		events = append(events, &Event{UUID: readUUID(data[0:16]), Daytimer: daytimer})
+		data = data[daytimerHeaderLength+count*daytimerEntryLength:]
+	}
+
+	e.Events = events
+}
+
+// Weather is the weather table of the weather service, an entry per hour
+type Weather struct {
+	// LastUpdate is in seconds since 2009-01-01, see Time
+	LastUpdate int64
+	Entries    []WeatherEntry
+}
+
+// WeatherEntry is the forecast of an hour
+type WeatherEntry struct {
+	// Timestamp is in seconds since 2009-01-01, see Time
+	Timestamp            int64
+	WeatherType          int32
+	WindDirection        int32
+	SolarRadiation       int32
+	RelativeHumidity     int32
+	Temperature          float64
+	PerceivedTemperature float64
+	DewPoint             float64
+	Precipitation        float64
+	WindSpeed            float64
+	BarometricPressure   float64
+}
+
+const (
+	weatherHeaderLength = 24
+	weatherEntryLength  = 68
+)
+
+// Time converts the seconds since 2009-01-01 of the Miniserver, in the time
+// zone of its clock
+func Time(seconds int64, location *time.Location) time.Time {
+	return time.Date(2009, 1, 1, 0, 0, 0, 0, location).Add(time.Duration(seconds) * time.Second)
+}
+
+// readWeather reads weather tables: the UUID of the weather server, the time
+// of the last update, the number of entries and the entries
+func (e *BinaryEvent) readWeather(dataRef *[]byte) {
+	data := *dataRef
+	events := make([]*Event, 0)
+	for len(data) >= weatherHeaderLength {
+		count := int(binary.LittleEndian.Uint32(data[20:24]))
+		if count > (len(data)-weatherHeaderLength)/weatherEntryLength {
+			break
+		}
+		weather := &Weather{
+			LastUpdate: int64(binary.LittleEndian.Uint32(data[16:20])),
+			Entries:    make([]WeatherEntry, 0, count),
+		}
+		for i := 0; i < count; i++ {
+			start := weatherHeaderLength + i*weatherEntryLength
+			weather.Entries = append(weather.Entries, readWeatherEntry(data[start:start+weatherEntryLength]))
+		}
+		events = append(events, &Event{UUID: readUUID(data[0:16]), Weather: weather})
+		data = data[weatherHeaderLength+count*weatherEntryLength:]
+	}
+
+	e.Events = events
+}
+
+func readWeatherEntry(data []byte) WeatherEntry {
+	int32At := func(offset int) int32 {
+		return int32(binary.LittleEndian.Uint32(data[offset : offset+4]))
+	}
+	float64At := func(offset int) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(data[offset : offset+8]))
+	}
+	return WeatherEntry{
+		Timestamp:            int64(int32At(0)),
+		WeatherType:          int32At(4),
+		WindDirection:        int32At(8),
+		SolarRadiation:       int32At(12),
+		RelativeHumidity:     int32At(16),
+		Temperature:          float64At(20),
+		PerceivedTemperature: float64At(28),
+		DewPoint:             float64At(36),
+		Precipitation:        float64At(44),
+		WindSpeed:            float64At(52),
+		BarometricPressure:   float64At(60),
+	}
+}
+
+func (e *BinaryEvent) readEvent(dataRef *[]byte) {
+	data := *dataRef
+	reader := bytes.NewReader(data)
+	// 1 EventTypeEvent = 24 Bytes
+	p := make([]byte, 24)
+	events := make([]*Event, 0)
+	for {
+		n, err := reader.Read(p)
+		if err == io.EOF {
+			break
+		}
+		events = append(events, createEvent(p[:n]))
+	}
+
+	e.Events = events
+}
+
+func createEvent(eventRaw []byte) *Event {
+	uuid := readUUID(eventRaw[0:16])
+	value := math.Float64frombits(binary.LittleEndian.Uint64(eventRaw[16:24]))
+	return &Event{
+		UUID:  uuid,
+		Value: value,
+	}
+}
+
+func IdentifyHeader(bytes []byte) (*Header, error) {
+	if len(bytes) != 8 {
+		return nil, errors.New("error: wrong binary Header received")
+	}
+	eventTypeValue := bytes[1]
+	length := int(binary.LittleEndian.Uint32(bytes[4:]))
+
+	estimated := false
+	if bytes[2] == 128 {
+		estimated = true
+	}
+
+	return &Header{
+		EventType: EventType(eventTypeValue),
+		Length:    length,
+		Estimated: estimated,
+	}, nil
+}
+
+func InitBinaryEvent(bytes *[]byte, eventType EventType) *BinaryEvent {
+	binaryEvent := &BinaryEvent{EventType: eventType}
+
+	switch eventType {
+	case EventTypeEventtext:
+		binaryEvent.readEventText(bytes)
+	case EventTypeEvent:
+		binaryEvent.readEvent(bytes)
+	case EventTypeDaytimer:
+		binaryEvent.readDaytimer(bytes)
+	case EventTypeWeather:
+		binaryEvent.readWeather(bytes)
+	}
+
+	return binaryEvent
+}
+
+func readUUID(data []byte) string {
+	values := []string{
+		extract32Bytes(data[0:4]),
+		extract16Bytes(data[4:6]),
+		extract16Bytes(data[6:8]),
+		extract64Bytes(data[8:16]),
+	}
+
+	return strings.Join(values, "-")
+}
+
+func extract16Bytes(data []byte) string {
+	b := make([]byte, len(data))
+	u := binary.LittleEndian.Uint16(data)
+	binary.BigEndian.PutUint16(b, u)
+	return hex.EncodeToString(b)
+}
+func extract32Bytes(data []byte) string {
+	b := make([]byte, len(data))
+	u := binary.LittleEndian.Uint32(data)
+	binary.BigEndian.PutUint32(b, u)
+	return hex.EncodeToString(b)
+}
+func extract64Bytes(data []byte) string {
+	return hex.EncodeToString(data)
+}
@@ -0,0 +1,151 @@
+package events
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDetectHeader(t *testing.T) {
+	compareHeader(&Header{Length: 353, EventType: EventTypeText, Estimated: false}, []byte{3, 0, 0, 0, 97, 1, 0, 0}, t)
+	compareHeader(&Header{Length: 320, EventType: EventTypeText, Estimated: false}, []byte{3, 0, 0, 0, 64, 1, 0, 0}, t)
+	compareHeader(&Header{Length: 76, EventType: EventTypeText, Estimated: false}, []byte{3, 0, 0, 0, 76, 0, 0, 0}, t)
+	compareHeader(&Header{Length: 48, EventType: EventTypeWeather, Estimated: false}, []byte{3, 7, 0, 0, 48, 0, 0, 0}, t)
+	compareHeader(&Header{Length: 2712, EventType: EventTypeEvent, Estimated: false}, []byte{3, 2, 0, 0, 152, 10, 0, 0}, t)
+	compareHeader(&Header{Length: 1496, EventType: EventTypeEventtext, Estimated: false}, []byte{3, 3, 0, 0, 216, 5, 0, 0}, t)
+	compareHeader(&Header{Length: 82888, EventType: EventTypeFile, Estimated: false}, []byte{3, 1, 0, 0, 200, 67, 1, 0}, t)
+	compareHeader(&Header{Length: 87636, EventType: EventTypeFile, Estimated: true}, []byte{3, 1, 128, 0, 84, 86, 1, 0}, t)
+}
+
+func compareHeader(expected *Header, bytes []byte, t *testing.T) {
+	result, _ := IdentifyHeader(bytes)
+	if !reflect.DeepEqual(expected, result) {
+		fmt.Printf("expected: %+v\n", expected)
+		fmt.Printf("result: %+v\n", result)
+		t.Error("Header are not equals")
+	}
+}
+
+func TestInitBinaryEvent(t *testing.T) {
+	data := []byte{82, 182, 253, 14, 16, 2, 194, 21, 255, 255, 33, 90, 21, 161, 245, 123, 107, 188, 116, 147, 24, 4, 182, 63}
+	eventType := EventTypeEvent
+
+	compareEvent(&BinaryEvent{
+		EventType: eventType,
+		Events: []*Event{{
+			UUID:  "0efdb652-0210-15c2-ffff215a15a1f57b",
+			Value: 0.08600000000000001,
+		},
+		}}, data, EventTypeEvent, t)
+}
+
+func compareEvent(expected *BinaryEvent, bytes []byte, eventType EventType, t *testing.T) {
+	result := InitBinaryEvent(&bytes, eventType)
+	if !reflect.DeepEqual(expected, result) {
+		fmt.Printf("expected: %+v\n", expected)
+		fmt.Printf("result: %+v\n", result)
+		t.Error("events are not equals")
+	}
+}
+
+func TestInitBinaryEventText(t *testing.T) {
+	uuid := []byte{82, 182, 253, 14, 16, 2, 194, 21, 255, 255, 33, 90, 21, 161, 245, 123}
+	icon := make([]byte, 16)
+	data := append(append([]byte{}, uuid...), icon...)
+	data = append(data, 5, 0, 0, 0)
+	data = append(data, []byte("hello")...)
+	data = append(data, 0, 0, 0)
+	data = append(data, uuid...)
+	data = append(data, icon...)
+	data = append(data, 0, 0, 0, 0)
+
+	compareEvent(&BinaryEvent{
+		EventType: EventTypeEventtext,
+		Events: []*Event{{
+			UUID: "0efdb652-0210-15c2-ffff215a15a1f57b",
+			Text: &Text{Icon: "00000000-0000-0000-0000000000000000", Value: "hello"},
+		}, {
+			UUID: "0efdb652-0210-15c2-ffff215a15a1f57b",
+			Text: &Text{Icon: "00000000-0000-0000-0000000000000000", Value: ""},
+		}},
+	}, data, EventTypeEventtext, t)
+}
+
+func TestInitBinaryEventTextTruncated(t *testing.T) {
+	data := make([]byte, 36)
+	data[32] = 100
+
+	compareEvent(&BinaryEvent{
+		EventType: EventTypeEventtext,
+		Events:    []*Event{},
+	}, data, EventTypeEventtext, t)
+}
+
+func TestInitBinaryEventWeather(t *testing.T) {
+	uuid := []byte{82, 182, 253, 14, 16, 2, 194, 21, 255, 255, 33, 90, 21, 161, 245, 123}
+	var buf bytes.Buffer
+	buf.Write(uuid)
+	binary.Write(&buf, binary.LittleEndian, []uint32{500000000, 2})
+	for i, temperature := range []float64{21.5, 20} {
+		binary.Write(&buf, binary.LittleEndian, []uint32{uint32(500000000 + 3600*i), 3, 270, 150, 60})
+		binary.Write(&buf, binary.LittleEndian, []float64{temperature, 20.5, 12, 0.4, 15, 1013})
+	}
+	data := buf.Bytes()
+	entry := WeatherEntry{WeatherType: 3, WindDirection: 270, SolarRadiation: 150, RelativeHumidity: 60,
+		PerceivedTemperature: 20.5, DewPoint: 12, Precipitation: 0.4, WindSpeed: 15, BarometricPressure: 1013}
+	first, second := entry, entry
+	first.Timestamp, first.Temperature = 500000000, 21.5
+	second.Timestamp, second.Temperature = 500003600, 20
+
+	compareEvent(&BinaryEvent{
+		EventType: EventTypeWeather,
+		Events: []*Event{{
+			UUID:    "0efdb652-0210-15c2-ffff215a15a1f57b",
+			Weather: &Weather{LastUpdate: 500000000, Entries: []WeatherEntry{first, second}},
+		}},
+	}, data, EventTypeWeather, t)
+
+	compareEvent(&BinaryEvent{
+		EventType: EventTypeWeather,
+		Events:    []*Event{},
+	}, data[:len(data)-1], EventTypeWeather, t)
+}
+
+func TestTime(t *testing.T) {
+	got := Time(3600, time.UTC)
+	if !got.Equal(time.Date(2009, 1, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("Time(3600) is %s", got)
+	}
+}
+
+func TestInitBinaryEventDaytimer(t *testing.T) {
+	uuid := []byte{82, 182, 253, 14, 16, 2, 194, 21, 255, 255, 33, 90, 21, 161, 245, 123}
+	var buf bytes.Buffer
+	buf.Write(uuid)
+	binary.Write(&buf, binary.LittleEndian, 18.5)
+	binary.Write(&buf, binary.LittleEndian, int32(2))
+	binary.Write(&buf, binary.LittleEndian, []int32{3, 360, 480, 0})
+	binary.Write(&buf, binary.LittleEndian, 21.0)
+	binary.Write(&buf, binary.LittleEndian, []int32{4, 1020, 1320, 1})
+	binary.Write(&buf, binary.LittleEndian, 22.5)
+	data := buf.Bytes()
+
+	compareEvent(&BinaryEvent{
+		EventType: EventTypeDaytimer,
+		Events: []*Event{{
+			UUID: "0efdb652-0210-15c2-ffff215a15a1f57b",
+			Daytimer: &Daytimer{DefaultValue: 18.5, Entries: []DaytimerEntry{
+				{Mode: 3, From: 360, To: 480, Value: 21},
+				{Mode: 4, From: 1020, To: 1320, NeedActivate: 1, Value: 22.5},
+			}},
+		}},
+	}, data, EventTypeDaytimer, t)
+
+	compareEvent(&BinaryEvent{
+		EventType: EventTypeDaytimer,
+		Events:    []*Event{},
+	}, data[:len(data)-1], EventTypeDaytimer, t)
+}
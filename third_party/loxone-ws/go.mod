@@ -0,0 +1,9 @@
+module github.com/XciD/loxone-ws
+
+go 1.12
+
+require (
+	github.com/gorilla/websocket v1.4.0
+	github.com/mitchellh/mapstructure v1.1.2
+	github.com/sirupsen/logrus v1.4.0
+)
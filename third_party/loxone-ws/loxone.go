@@ -0,0 +1,783 @@
+package loxone
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/XciD/loxone-ws/crypto"
+	"github.com/XciD/loxone-ws/events"
+
+	"github.com/gorilla/websocket"
+	"github.com/mitchellh/mapstructure"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	getPublicKey                 = "jdev/sys/getPublicKey"
+	keyExchange                  = "jdev/sys/keyexchange/%s"
+	getUsersalt                  = "jdev/sys/getkey2/%s"
+	getToken                     = "jdev/sys/gettoken/%s/%s/%d/%s/%s" // #nosec
+	getKey                       = "jdev/sys/getkey"
+	refreshToken                 = "jdev/sys/refreshjwt/%s/%s" // #nosec
+	aesPayload                   = "salt/%s/%s"
+	encryptionCmd                = "jdev/sys/enc/%s"
+	encryptionCommandAndResponse = "jdev/sys/fenc/%s"
+	registerEvents               = "jdev/sps/enablebinstatusupdate"
+	getConfig                    = "data/LoxAPP3.json"
+)
+
+// Body response form command sent by ws
+type Body struct {
+	// Control name of the control invoked
+	Control string
+	// Code status
+	Code int32
+}
+
+// SimpleValue represent a simple Loxone Response Value
+type SimpleValue struct {
+	// The value answered
+	Value string
+}
+
+// Config represent the LoxAPP3.json config file
+type Config struct {
+	// LastModified
+	LastModified string
+	// MsInfo
+	MsInfo map[string]interface{}
+	// GlobalStates states about the sun, the day, etc
+	GlobalStates map[string]string
+	// OperatingModes of the loxone server
+	OperatingModes map[string]interface{}
+	// Rooms of the loxone server
+	Rooms map[string]*Room
+	// Cats Categories of the loxone server
+	Cats map[string]*Category
+	// Controls all the control of the loxone server
+	Controls map[string]*Control
+}
+
+func (cfg *Config) CatName(key interface{}) string {
+	k, ok := key.(string)
+	if !ok {
+		return ""
+	}
+	cat, ok := cfg.Cats[k]
+	if !ok {
+		return ""
+	}
+	return cat.Name
+}
+
+func (cfg *Config) RoomName(key interface{}) string {
+	k, ok := key.(string)
+	if !ok {
+		return ""
+	}
+	room, ok := cfg.Rooms[k]
+	if !ok {
+		return ""
+	}
+	return room.Name
+}
+
+// Control represent a control
+type Control struct {
+	Name       string
+	Type       string
+	UUIDAction string
+	IsFavorite bool `json:"isFavorite"`
+	Room       string
+	Cat        string
+	States     map[string]interface{} // Can be an array or a string
+}
+
+// Room represent a room
+type Room struct {
+	Name string
+	UUID string
+	Type int32
+}
+
+// Category represent a category
+type Category struct {
+	Name string
+	UUID string
+	Type string
+}
+
+// Loxone The loxone object exposed
+type Loxone struct {
+	host            string
+	user            string
+	password        string
+	encrypt         *encrypt
+	token           *token
+	tokenLock       sync.Mutex
+	Events          chan *events.Event
+	callbackChannel chan *websocketResponse
+	socketMessage   chan *[]byte
+	socket          *websocket.Conn
+	stop            chan struct{}
+	stopOnce        sync.Once
+	hooks           map[string]func(*events.Event)
+	registerEvents  bool
+	dialer          *websocket.Dialer
+	httpClient      *http.Client
+	permission      int
+}
+
+// Options are the connection settings of NewWithOptions
+type Options struct {
+	// Dialer opens the websocket, websocket.DefaultDialer by default
+	Dialer *websocket.Dialer
+	// HTTPClient downloads the public key before the login, a client with
+	// http.DefaultTransport by default
+	HTTPClient *http.Client
+	// Permission of the token, PermissionWeb or PermissionApp, PermissionApp by default
+	Permission int
+}
+
+const (
+	// PermissionWeb requests a short lived token
+	PermissionWeb = 2
+	// PermissionApp requests a long lived token
+	PermissionApp = 4
+)
+
+type websocketResponse struct {
+	data         *[]byte
+	responseType events.EventType
+}
+
+type encrypt struct {
+	publicKey   *rsa.PublicKey
+	key         string
+	iv          string
+	timestamp   time.Time
+	oneTimeSalt string
+	salt        string
+}
+
+type token struct {
+	Token string `mapstructure:"token"`
+	Key   string `mapstructure:"key"`
+	// ValidUntil is in seconds since 2009-01-01 UTC
+	ValidUntil   int64 `mapstructure:"validUntil"`
+	TokenRights  int32 `mapstructure:"tokenRights"`
+	UnsecurePass bool  `mapstructure:"unsecurePass"`
+}
+
+type salt struct {
+	OneTimeSalt string `mapstructure:"key"`
+	Salt        string `mapstructure:"Salt"`
+}
+
+type encryptType int32
+
+const (
+	none encryptType = 0
+	//request            encryptType = 1
+	requestResponseVal encryptType = 2
+)
+
+// Connect to the loxone websocket
+func New(host string, user string, password string) (*Loxone, error) {
+	return NewWithOptions(host, user, password, Options{})
+}
+
+// NewWithOptions connects to the loxone websocket with the dialer and HTTP
+// client of the options
+func NewWithOptions(host string, user string, password string, options Options) (*Loxone, error) {
+
+	// Check if all mandatory parameters were given
+	if host == "" {
+		return nil, errors.New("missing host")
+	}
+	if user == "" {
+		return nil, errors.New("missing user")
+	}
+	if password == "" {
+		return nil, errors.New("missing password")
+	}
+
+	loxone := &Loxone{
+		Events:          make(chan *events.Event),
+		host:            host,
+		user:            user,
+		password:        password,
+		registerEvents:  false,
+		callbackChannel: make(chan *websocketResponse),
+		stop:            make(chan struct{}),
+		hooks:           make(map[string]func(*events.Event)),
+		socketMessage:   make(chan *[]byte),
+		dialer:          options.Dialer,
+		httpClient:      options.HTTPClient,
+		permission:      options.Permission,
+	}
+	if loxone.dialer == nil {
+		loxone.dialer = websocket.DefaultDialer
+	}
+	if loxone.httpClient == nil {
+		loxone.httpClient = &http.Client{}
+	}
+	if loxone.permission == 0 {
+		loxone.permission = PermissionApp
+	}
+
+	go loxone.handleMessages()
+
+	err := loxone.connect()
+
+	if err != nil {
+		loxone.Close()
+		return nil, err
+	}
+
+	return loxone, nil
+}
+
+func (l *Loxone) connect() error {
+	err := l.connectWs()
+
+	if err != nil {
+		return err
+	}
+
+	err = l.authenticate()
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Close closes the connection and stops the goroutines of the client, it
+// can be called more than once
+func (l *Loxone) Close() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+		if l.socket != nil {
+			_ = l.socket.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			l.socket.Close()
+		}
+	})
+}
+
+// Done is closed when the connection is lost or closed, the client doesn't
+// reconnect on its own
+func (l *Loxone) Done() <-chan struct{} {
+	return l.stop
+}
+
+// RegisterEvents ask the loxone server to send events
+func (l *Loxone) RegisterEvents() error {
+	l.registerEvents = true
+
+	_, err := l.SendCommand(registerEvents, nil)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AddHook ask the loxone server to send events
+func (l *Loxone) AddHook(uuid string, callback func(*events.Event)) {
+	l.hooks[uuid] = callback
+}
+
+func (l *Loxone) PumpEvents(stop <-chan bool) {
+	go func() {
+		for {
+			select {
+			case <-stop:
+				log.Infof("Shutting Down")
+				return
+			case event := <-l.Events:
+				if hook, ok := l.hooks[event.UUID]; ok {
+					hook(event)
+				}
+				log.Debugf("event: %+v\n", event)
+			}
+		}
+	}()
+}
+
+// GetConfig get the loxone server config
+func (l *Loxone) GetConfig() (*Config, error) {
+	config := &Config{}
+
+	_, err := l.SendCommand(getConfig, config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// SendCommand Send a command to the loxone server
+func (l *Loxone) SendCommand(cmd string, class interface{}) (*Body, error) {
+	return l.sendCmdWithEnc(cmd, none, class)
+}
+
+func (l *Loxone) sendCmdWithEnc(cmd string, encryptType encryptType, class interface{}) (*Body, error) {
+	encryptedCmd, err := l.encrypt.getEncryptedCmd(cmd, encryptType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := l.sendSocketCmd(&encryptedCmd)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if encryptType == requestResponseVal {
+		// We need to decrypt
+		decryptedResult, err := l.encrypt.decryptCmd(*result.data)
+		if err != nil {
+			return nil, err
+		}
+		result.data = &decryptedResult
+	}
+
+	if class != nil {
+		if result.responseType == events.EventTypeText {
+			body, err := deserializeLoxoneResponse(result.data, class)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if body.Code != 200 {
+				return nil, fmt.Errorf("error server, code: %d", body.Code)
+			}
+			return body, nil
+		} else if result.responseType == events.EventTypeFile {
+			err := json.Unmarshal(*result.data, &class)
+			if err != nil {
+				return nil, err
+			}
+			// Response is copied to class
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unHandled response type: %d", result.responseType)
+	}
+
+	log.Debug(string(*result.data))
+	return &Body{Code: 200}, nil
+}
+
+func (l *Loxone) sendSocketCmd(cmd *[]byte) (*websocketResponse, error) {
+	log.Debug("Sending command to WS")
+	err := l.socket.WriteMessage(websocket.TextMessage, *cmd)
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Waiting for answer")
+	select {
+	case result := <-l.callbackChannel:
+		log.Debugf("WS answered")
+		return result, nil
+	case <-l.stop:
+		return nil, errors.New("connection closed")
+	}
+}
+
+func (l *Loxone) authenticate() error {
+	// Retrieve public key
+	log.Info("Asking for Public Key")
+	publicKey, err := getPublicKeyFromServer(l.httpClient, l.host)
+
+	if err != nil {
+		return err
+	}
+
+	log.Info("Public Key OK")
+
+	// Create an unique key and an iv for AES
+	uniqueID := crypto.CreateEncryptKey(32)
+	ivKey := crypto.CreateEncryptKey(16)
+
+	// encrypt both and send them to server to get a Salt
+	cipherMessage, err := crypto.EncryptWithPublicKey([]byte(fmt.Sprintf("%s:%s", uniqueID, ivKey)), publicKey)
+
+	if err != nil {
+		return err
+	}
+
+	log.Info("Key Exchange with Miniserver")
+	resultValue := &SimpleValue{}
+	_, err = l.sendCmdWithEnc(fmt.Sprintf(keyExchange, cipherMessage), none, resultValue)
+
+	if err != nil {
+		return err
+	}
+
+	salt, err := crypto.DecryptAES(resultValue.Value, uniqueID, ivKey)
+
+	if err != nil {
+		return err
+	}
+
+	l.encrypt = &encrypt{
+		publicKey:   publicKey,
+		key:         uniqueID,
+		iv:          ivKey,
+		oneTimeSalt: string(salt),
+		timestamp:   time.Now(),
+		salt:        crypto.CreateEncryptKey(2),
+	}
+
+	log.Info("Key Exchange OK")
+	log.Info("Authentication Starting")
+
+	err = l.createToken(l.user, l.password, uniqueID)
+
+	if err != nil {
+		return err
+	}
+
+	log.Info("Authentication OK")
+
+	return nil
+}
+
+func (l *Loxone) createToken(user string, password string, uniqueID string) error {
+	cmd := fmt.Sprintf(getUsersalt, user)
+
+	salt := &salt{}
+	_, err := l.sendCmdWithEnc(cmd, requestResponseVal, salt)
+
+	if err != nil {
+		return err
+	}
+
+	hash := l.encrypt.hashUser(user, password, salt.Salt, salt.OneTimeSalt)
+
+	cmd = fmt.Sprintf(getToken, hash, user, l.permission, uniqueID, "GO")
+
+	token := &token{}
+	_, err = l.sendCmdWithEnc(cmd, requestResponseVal, token)
+
+	if err != nil {
+		return err
+	}
+
+	l.tokenLock.Lock()
+	l.token = token
+	l.tokenLock.Unlock()
+	return nil
+}
+
+// TokenValidUntil returns when the token expires, the zero time if the
+// Miniserver didn't tell
+func (l *Loxone) TokenValidUntil() time.Time {
+	l.tokenLock.Lock()
+	defer l.tokenLock.Unlock()
+	if l.token == nil || l.token.ValidUntil == 0 {
+		return time.Time{}
+	}
+	return events.Time(l.token.ValidUntil, time.UTC)
+}
+
+// RefreshToken extends the validity of the token, it must be called before
+// the token expires
+func (l *Loxone) RefreshToken() error {
+	l.tokenLock.Lock()
+	current := l.token
+	l.tokenLock.Unlock()
+	if current == nil || current.Token == "" {
+		return errors.New("no token to refresh")
+	}
+
+	key := &SimpleValue{}
+	_, err := l.sendCmdWithEnc(getKey, none, key)
+	if err != nil {
+		return err
+	}
+
+	hash := crypto.ComputeHmac256(current.Token, key.Value)
+	refreshed := &token{}
+	_, err = l.sendCmdWithEnc(fmt.Sprintf(refreshToken, hash, l.user), requestResponseVal, refreshed)
+	if err != nil {
+		return err
+	}
+
+	// The key of the token stays the same
+	refreshed.Key = current.Key
+	if refreshed.Token == "" {
+		refreshed.Token = current.Token
+	}
+	l.tokenLock.Lock()
+	l.token = refreshed
+	l.tokenLock.Unlock()
+	return nil
+}
+
+func (l *Loxone) connectWs() error {
+	log.Info("Connecting to WS")
+	u := url.URL{Scheme: "ws", Host: l.host, Path: "/ws/rfc6455?_=" + strconv.FormatInt(time.Now().Unix(), 10)}
+
+	socket, _, err := l.dialer.Dial(u.String(), nil)
+	if err != nil {
+		return err
+	}
+	l.socket = socket
+
+	go l.readPump(socket)
+
+	return nil
+}
+
+func (l *Loxone) readPump(socket *websocket.Conn) {
+	defer func() {
+		log.Info("Stopping websocket pump")
+		l.Close()
+	}()
+	log.Info("Starting websocket pump")
+
+	for {
+		_, message, err := socket.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("error: %v", err)
+			}
+			return
+		}
+
+		log.Trace("Pushing new message from socket to socket channel")
+		select {
+		case l.socketMessage <- &message:
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+func (l *Loxone) handleMessages() {
+	incomingData := events.EmptyHeader
+	var err error
+
+	defer func() {
+		log.Info("Stopping message handling")
+	}()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case message := <-l.socketMessage:
+			log.Trace("Sub new message from socket channel")
+
+			// Check if we received an header or not
+			if len(*message) == 8 {
+				// we got an LX-Bin-header!
+				incomingData, err = events.IdentifyHeader(*message)
+				if err != nil {
+					log.Debugf("Error during identify header %v", err)
+					incomingData = events.EmptyHeader
+				} else if incomingData.Length == 0 && incomingData.EventType != events.EventTypeOutofservice && incomingData.EventType != events.EventTypeKeepalive {
+					log.Debug("received header telling 0 bytes payload - resolve request with null!")
+					// TODO sendOnBinaryMessage
+					incomingData = events.EmptyHeader
+				} else {
+					log.Debugf("Received header: %+v\n", incomingData)
+
+					if incomingData.EventType == events.EventTypeOutofservice {
+						log.Warn("Miniserver out of service!")
+						continue
+					}
+
+					if incomingData.EventType == events.EventTypeKeepalive {
+						log.Debug("KeepAlive")
+						incomingData = events.EmptyHeader
+						continue
+					}
+					// Waiting for the data
+					continue
+				}
+
+			} else if !incomingData.Empty && incomingData.Length == len(*message) {
+				// Received message
+				switch incomingData.EventType {
+				case events.EventTypeText:
+					log.Debug("Received a text message from previous header")
+					l.answer(&websocketResponse{data: message, responseType: incomingData.EventType})
+				case events.EventTypeFile:
+					log.Debug("Received a file from previous header")
+					l.answer(&websocketResponse{data: message, responseType: incomingData.EventType})
+				case events.EventTypeEvent:
+					l.handleBinaryEvent(message, incomingData.EventType)
+				case events.EventTypeEventtext:
+					l.handleBinaryEvent(message, incomingData.EventType)
+				case events.EventTypeDaytimer:
+					l.handleBinaryEvent(message, incomingData.EventType)
+				case events.EventTypeWeather:
+					l.handleBinaryEvent(message, incomingData.EventType)
+				default:
+					log.Warnf("Unknown event %d", incomingData.EventType)
+				}
+
+				incomingData = events.EmptyHeader
+			} else {
+				log.Debug("Received binary message without header")
+				// TODO Send to error
+			}
+		}
+	}
+}
+
+// answer hands the answer to the command waiting for it, unless the client is closed
+func (l *Loxone) answer(response *websocketResponse) {
+	select {
+	case l.callbackChannel <- response:
+	case <-l.stop:
+	}
+}
+
+func (l *Loxone) handleBinaryEvent(binaryEvent *[]byte, eventType events.EventType) {
+	events := events.InitBinaryEvent(binaryEvent, eventType)
+
+	for _, event := range events.Events {
+		select {
+		case l.Events <- event:
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+func (e *encrypt) hashUser(user string, password string, salt string, oneTimeSalt string) string {
+	// create a SHA1 hash of the (salted) password
+	hash := strings.ToUpper(crypto.Sha1Hash(fmt.Sprintf("%s:%s", password, salt)))
+
+	// hash with user and otSalt
+	return crypto.ComputeHmac256(fmt.Sprintf("%s:%s", user, hash), oneTimeSalt)
+}
+
+func (e *encrypt) getEncryptedCmd(cmd string, encryptType encryptType) ([]byte, error) {
+	if encryptType == none {
+		return []byte(cmd), nil
+	}
+
+	// TODO code next Salt
+	cmd = fmt.Sprintf(aesPayload, e.salt, cmd)
+	cipher, err := crypto.EncryptAES(cmd, e.key, e.iv)
+
+	if err != nil {
+		return nil, err
+	}
+
+	format := encryptionCmd
+	if encryptType == requestResponseVal {
+		format = encryptionCommandAndResponse
+	}
+
+	escape := fmt.Sprintf(format, url.QueryEscape(cipher))
+	return []byte(escape), nil
+}
+
+func (e *encrypt) decryptCmd(cipherText []byte) ([]byte, error) {
+	return crypto.DecryptAES(string(cipherText), e.key, e.iv)
+}
+
+func getPublicKeyFromServer(client *http.Client, url string) (*rsa.PublicKey, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/%s", url, getPublicKey), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey := &SimpleValue{}
+	_, err = deserializeLoxoneResponse(&body, publicKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if publicKey.Value == "" {
+		return nil, errors.New("pub key is empty")
+	}
+
+	return crypto.BytesToPublicKey(publicKey.Value)
+}
+
+func deserializeLoxoneResponse(jsonBytes *[]byte, class interface{}) (*Body, error) {
+	raw := make(map[string]interface{})
+	err := json.Unmarshal(*jsonBytes, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	ll := raw["LL"].(map[string]interface{})
+
+	body := &Body{Control: ll["control"].(string)}
+
+	var code interface{}
+	// If can be on Code or code...
+	if val, ok := ll["Code"]; ok {
+		code = val
+	}
+	if val, ok := ll["code"]; ok {
+		code = val
+	}
+
+	// Can be a string or a float...
+	switch code := code.(type) {
+	case string:
+		i, _ := strconv.ParseInt(code, 10, 32)
+		body.Code = int32(i)
+	case float64:
+		body.Code = int32(code)
+	}
+
+	// Deserialize value
+	switch ll["value"].(type) {
+	case string:
+		rv := reflect.ValueOf(class).Elem()
+		rv.FieldByName("Value").SetString(ll["value"].(string))
+	case map[string]interface{}:
+		err := mapstructure.Decode(ll["value"], &class)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return body, nil
+}